@@ -0,0 +1,377 @@
+// Package alert evaluates alert rules against controller observations and
+// sends notifications when they fire or resolve.
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Rule types.
+const (
+	RuleTemperature  = "temperature"
+	RuleHumidity     = "humidity"
+	RuleSensorError  = "sensor_error"
+	RuleStaleReading = "stale_reading"
+	RuleRelayFailure = "relay_failure"
+	RuleCriticalMode = "critical_mode"
+)
+
+// Severities, in increasing order.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Alert states.
+const (
+	StateOK      = "ok"
+	StatePending = "pending"
+	StateFiring  = "firing"
+)
+
+var severityRank = map[string]int{SeverityInfo: 0, SeverityWarning: 1, SeverityCritical: 2}
+
+// Rule raises an alert once its condition has held for ForSeconds. Min and
+// Max bound temperature and humidity rules; MaxAgeSeconds is the oldest
+// acceptable reading for stale reading rules. Notifications for a rule are
+// at least CooldownMinutes apart, and repeat at that interval while the
+// alert fires unacknowledged.
+type Rule struct {
+	ID              string   `json:"id"`
+	Type            string   `json:"type"`
+	Severity        string   `json:"severity"`
+	Enabled         bool     `json:"enabled"`
+	Min             *float32 `json:"min,omitempty"`
+	Max             *float32 `json:"max,omitempty"`
+	MaxAgeSeconds   int      `json:"max_age_seconds,omitempty"`
+	ForSeconds      int      `json:"for_seconds"`
+	CooldownMinutes int      `json:"cooldown_minutes"`
+}
+
+// UnmarshalJSON starts from a zero rule: encoding/json decodes into the
+// existing elements of a slice, which would keep bounds from the defaults.
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	type plain Rule
+	var decoded plain
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*r = Rule(decoded)
+	return nil
+}
+
+// Observation is the controller state a rule is evaluated against.
+type Observation struct {
+	Time        time.Time
+	Temperature float32
+	Humidity    float32
+	SensorError bool
+	LastRead    time.Time
+	RelayFault  string
+	SystemMode  string
+}
+
+// Event is a notification about an alert changing state.
+type Event struct {
+	RuleID   string    `json:"rule_id"`
+	Type     string    `json:"type"`
+	Severity string    `json:"severity"`
+	State    string    `json:"state"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+	Reminder bool      `json:"reminder,omitempty"`
+}
+
+// Status is the current state of one rule.
+type Status struct {
+	Rule         Rule       `json:"rule"`
+	State        string     `json:"state"`
+	Message      string     `json:"message,omitempty"`
+	Since        *time.Time `json:"since,omitempty"`
+	FiredAt      *time.Time `json:"fired_at,omitempty"`
+	LastNotified *time.Time `json:"last_notified,omitempty"`
+	Acknowledged bool       `json:"acknowledged"`
+	SnoozedUntil *time.Time `json:"snoozed_until,omitempty"`
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// ValidateRules rejects unknown types and severities, duplicate IDs and
+// rules missing the fields their type needs.
+func ValidateRules(rules []Rule) error {
+	seen := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		if rule.ID == "" {
+			return fmt.Errorf("rule %d has no id", i)
+		}
+		if seen[rule.ID] {
+			return fmt.Errorf("duplicate rule id %q", rule.ID)
+		}
+		seen[rule.ID] = true
+		if _, ok := severityRank[rule.Severity]; !ok {
+			return fmt.Errorf("rule %q: unknown severity %q", rule.ID, rule.Severity)
+		}
+		if rule.ForSeconds < 0 || rule.CooldownMinutes < 0 {
+			return fmt.Errorf("rule %q: negative duration", rule.ID)
+		}
+		switch rule.Type {
+		case RuleTemperature, RuleHumidity:
+			if rule.Min == nil && rule.Max == nil {
+				return fmt.Errorf("rule %q needs min or max", rule.ID)
+			}
+			if rule.Min != nil && rule.Max != nil && *rule.Min > *rule.Max {
+				return fmt.Errorf("rule %q: min %.1f above max %.1f", rule.ID, *rule.Min, *rule.Max)
+			}
+		case RuleStaleReading:
+			if rule.MaxAgeSeconds <= 0 {
+				return fmt.Errorf("rule %q needs max_age_seconds", rule.ID)
+			}
+		case RuleSensorError, RuleRelayFailure, RuleCriticalMode:
+		default:
+			return fmt.Errorf("rule %q: unknown type %q", rule.ID, rule.Type)
+		}
+	}
+	return nil
+}
+
+// SeverityAtLeast reports whether severity is at or above min; an empty
+// min accepts everything.
+func SeverityAtLeast(severity, min string) bool {
+	if min == "" {
+		return true
+	}
+	return severityRank[severity] >= severityRank[min]
+}
+
+func outOfRange(value float32, rule Rule) bool {
+	return (rule.Min != nil && value < *rule.Min) || (rule.Max != nil && value > *rule.Max)
+}
+
+func describeRange(name, unit string, value float32, rule Rule) string {
+	switch {
+	case rule.Min != nil && value < *rule.Min:
+		return fmt.Sprintf("%s %.1f%s below %.1f%s", name, value, unit, *rule.Min, unit)
+	case rule.Max != nil && value > *rule.Max:
+		return fmt.Sprintf("%s %.1f%s above %.1f%s", name, value, unit, *rule.Max, unit)
+	}
+	return fmt.Sprintf("%s %.1f%s in range", name, value, unit)
+}
+
+// check reports whether the rule's condition holds, with a description.
+func (rule Rule) check(obs Observation) (bool, string) {
+	switch rule.Type {
+	case RuleTemperature:
+		if obs.SensorError {
+			return false, ""
+		}
+		return outOfRange(obs.Temperature, rule), describeRange("Temperature", "°C", obs.Temperature, rule)
+	case RuleHumidity:
+		if obs.SensorError {
+			return false, ""
+		}
+		return outOfRange(obs.Humidity, rule), describeRange("Humidity", "%", obs.Humidity, rule)
+	case RuleSensorError:
+		return obs.SensorError, "Sensor read failing"
+	case RuleStaleReading:
+		if obs.LastRead.IsZero() {
+			return false, ""
+		}
+		age := obs.Time.Sub(obs.LastRead)
+		return age > time.Duration(rule.MaxAgeSeconds)*time.Second,
+			fmt.Sprintf("Last sensor reading %v old", age.Round(time.Second))
+	case RuleRelayFailure:
+		return obs.RelayFault != "", fmt.Sprintf("Relay failure: %s", obs.RelayFault)
+	case RuleCriticalMode:
+		return obs.SystemMode == "critical", "Controller in critical mode"
+	}
+	return false, ""
+}
+
+type ruleState struct {
+	state        string
+	message      string
+	since        time.Time
+	firedAt      time.Time
+	lastNotified time.Time
+	notifiedFire bool
+	acknowledged bool
+	snoozedUntil time.Time
+}
+
+// Engine tracks the state of each rule between evaluations.
+type Engine struct {
+	mu     sync.Mutex
+	rules  []Rule
+	states map[string]*ruleState
+}
+
+func NewEngine() *Engine {
+	return &Engine{states: make(map[string]*ruleState)}
+}
+
+// Evaluate updates every enabled rule against obs and returns the events
+// that should be sent. State for rules no longer configured is dropped.
+func (e *Engine) Evaluate(rules []Rule, obs Observation) []Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.rules = slices.Clone(rules)
+	active := make(map[string]bool, len(rules))
+	var events []Event
+	for _, rule := range rules {
+		active[rule.ID] = true
+		st, ok := e.states[rule.ID]
+		if !ok {
+			st = &ruleState{state: StateOK}
+			e.states[rule.ID] = st
+		}
+
+		holds, message := false, ""
+		if rule.Enabled {
+			holds, message = rule.check(obs)
+		}
+		if event, ok := e.advance(rule, st, holds, message, obs.Time); ok {
+			events = append(events, event)
+		}
+	}
+	for id := range e.states {
+		if !active[id] {
+			delete(e.states, id)
+		}
+	}
+	return events
+}
+
+func (e *Engine) advance(rule Rule, st *ruleState, holds bool, message string, now time.Time) (Event, bool) {
+	cooldown := time.Duration(rule.CooldownMinutes) * time.Minute
+	event := Event{RuleID: rule.ID, Type: rule.Type, Severity: rule.Severity, Time: now}
+	canNotify := now.After(st.snoozedUntil) &&
+		(st.lastNotified.IsZero() || now.Sub(st.lastNotified) >= cooldown)
+
+	if !holds {
+		wasFiring := st.state == StateFiring
+		notified := st.notifiedFire
+		*st = ruleState{state: StateOK, lastNotified: st.lastNotified, snoozedUntil: st.snoozedUntil}
+		if wasFiring && notified && now.After(st.snoozedUntil) {
+			st.lastNotified = now
+			event.State = "resolved"
+			event.Message = fmt.Sprintf("%s resolved", rule.ID)
+			return event, true
+		}
+		return Event{}, false
+	}
+
+	st.message = message
+	switch st.state {
+	case StateOK:
+		st.state = StatePending
+		st.since = now
+		fallthrough
+	case StatePending:
+		if now.Sub(st.since) < time.Duration(rule.ForSeconds)*time.Second {
+			return Event{}, false
+		}
+		st.state = StateFiring
+		st.firedAt = now
+		if !canNotify {
+			return Event{}, false
+		}
+	case StateFiring:
+		// Remind while unacknowledged, and send a late first notification
+		// once a snooze or cooldown that suppressed it has passed.
+		if st.acknowledged || !canNotify || (st.notifiedFire && cooldown == 0) {
+			return Event{}, false
+		}
+		event.Reminder = st.notifiedFire
+	}
+
+	st.lastNotified = now
+	st.notifiedFire = true
+	event.State = StateFiring
+	event.Message = message
+	return event, true
+}
+
+// Statuses returns the state of every configured rule in rule order.
+func (e *Engine) Statuses() []Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	statuses := make([]Status, 0, len(e.rules))
+	for _, rule := range e.rules {
+		status := Status{Rule: rule, State: StateOK}
+		if st, ok := e.states[rule.ID]; ok {
+			status.State = st.state
+			status.Message = st.message
+			status.Since = timePtr(st.since)
+			status.FiredAt = timePtr(st.firedAt)
+			status.LastNotified = timePtr(st.lastNotified)
+			status.Acknowledged = st.acknowledged
+			status.SnoozedUntil = timePtr(st.snoozedUntil)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Acknowledge stops reminders for a firing alert until it resolves.
+func (e *Engine) Acknowledge(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	st, ok := e.states[id]
+	if !ok {
+		return fmt.Errorf("unknown alert %q", id)
+	}
+	if st.state != StateFiring {
+		return fmt.Errorf("alert %q is not firing", id)
+	}
+	st.acknowledged = true
+	return nil
+}
+
+// Snooze suppresses notifications for the rule until the given time; a
+// zero time cancels the snooze.
+func (e *Engine) Snooze(id string, until time.Time) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	st, ok := e.states[id]
+	if !ok {
+		return fmt.Errorf("unknown alert %q", id)
+	}
+	st.snoozedUntil = until
+	return nil
+}
+
+func float32Ptr(v float32) *float32 {
+	return &v
+}
+
+// DefaultRules covers the conditions every enclosure should alert on.
+func DefaultRules() []Rule {
+	return []Rule{
+		{ID: "temperature", Type: RuleTemperature, Severity: SeverityWarning, Enabled: true,
+			Min: float32Ptr(18), Max: float32Ptr(35), ForSeconds: 300, CooldownMinutes: 30},
+		{ID: "humidity", Type: RuleHumidity, Severity: SeverityWarning, Enabled: true,
+			Min: float32Ptr(40), Max: float32Ptr(95), ForSeconds: 600, CooldownMinutes: 30},
+		{ID: "sensor_error", Type: RuleSensorError, Severity: SeverityWarning, Enabled: true,
+			ForSeconds: 60, CooldownMinutes: 30},
+		{ID: "stale_reading", Type: RuleStaleReading, Severity: SeverityCritical, Enabled: true,
+			MaxAgeSeconds: 300, CooldownMinutes: 30},
+		{ID: "relay_failure", Type: RuleRelayFailure, Severity: SeverityCritical, Enabled: true,
+			ForSeconds: 30, CooldownMinutes: 30},
+		{ID: "critical_mode", Type: RuleCriticalMode, Severity: SeverityCritical, Enabled: true,
+			CooldownMinutes: 30},
+	}
+}
@@ -0,0 +1,154 @@
+package alert
+
+import (
+	"testing"
+	"time"
+)
+
+var start = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func hotRule() Rule {
+	return Rule{ID: "hot", Type: RuleTemperature, Severity: SeverityWarning, Enabled: true,
+		Max: float32Ptr(30), ForSeconds: 60, CooldownMinutes: 10}
+}
+
+// evaluate runs the engine at start+offset with the given temperature.
+func evaluate(e *Engine, rule Rule, offset time.Duration, temperature float32) []Event {
+	return e.Evaluate([]Rule{rule}, Observation{Time: start.Add(offset), Temperature: temperature})
+}
+
+func expectEvents(t *testing.T, events []Event, want ...string) {
+	t.Helper()
+	if len(events) != len(want) {
+		t.Fatalf("got %d events %+v, want %v", len(events), events, want)
+	}
+	for i, event := range events {
+		state := event.State
+		if event.Reminder {
+			state = "reminder"
+		}
+		if state != want[i] {
+			t.Fatalf("event %d is %s, want %s", i, state, want[i])
+		}
+	}
+}
+
+func TestEngineForDelayCooldownAndResolve(t *testing.T) {
+	e := NewEngine()
+	rule := hotRule()
+
+	expectEvents(t, evaluate(e, rule, 0, 35))
+	if state := e.Statuses()[0].State; state != StatePending {
+		t.Fatalf("state %s, want pending during for_seconds", state)
+	}
+	expectEvents(t, evaluate(e, rule, 30*time.Second, 35))
+	expectEvents(t, evaluate(e, rule, 60*time.Second, 35), StateFiring)
+
+	// Reminders wait for the cooldown.
+	expectEvents(t, evaluate(e, rule, 5*time.Minute, 35))
+	expectEvents(t, evaluate(e, rule, 11*time.Minute, 35), "reminder")
+
+	expectEvents(t, evaluate(e, rule, 12*time.Minute, 25), "resolved")
+	if state := e.Statuses()[0].State; state != StateOK {
+		t.Fatalf("state %s, want ok after resolving", state)
+	}
+}
+
+func TestEngineConditionClearingDuringForResets(t *testing.T) {
+	e := NewEngine()
+	rule := hotRule()
+
+	expectEvents(t, evaluate(e, rule, 0, 35))
+	expectEvents(t, evaluate(e, rule, 45*time.Second, 25))
+	expectEvents(t, evaluate(e, rule, 50*time.Second, 35))
+	// Only 50s after the condition came back: still pending.
+	expectEvents(t, evaluate(e, rule, 100*time.Second, 35))
+	expectEvents(t, evaluate(e, rule, 110*time.Second, 35), StateFiring)
+}
+
+func TestEngineAcknowledgeStopsReminders(t *testing.T) {
+	e := NewEngine()
+	rule := hotRule()
+	rule.ForSeconds = 0
+
+	if err := e.Acknowledge(rule.ID); err == nil {
+		t.Fatal("acknowledging an unknown alert should fail")
+	}
+	expectEvents(t, evaluate(e, rule, 0, 35), StateFiring)
+	if err := e.Acknowledge(rule.ID); err != nil {
+		t.Fatal(err)
+	}
+	expectEvents(t, evaluate(e, rule, 30*time.Minute, 35))
+
+	// The resolution is still reported, and the next firing notifies.
+	expectEvents(t, evaluate(e, rule, 31*time.Minute, 25), "resolved")
+	expectEvents(t, evaluate(e, rule, 45*time.Minute, 35), StateFiring)
+	if e.Statuses()[0].Acknowledged {
+		t.Fatal("acknowledgement should not carry over to a new firing")
+	}
+}
+
+func TestEngineSnoozeDelaysFirstNotification(t *testing.T) {
+	e := NewEngine()
+	rule := hotRule()
+	rule.ForSeconds = 0
+
+	expectEvents(t, evaluate(e, rule, 0, 25))
+	if err := e.Snooze(rule.ID, start.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	expectEvents(t, evaluate(e, rule, time.Minute, 35))
+	if state := e.Statuses()[0].State; state != StateFiring {
+		t.Fatalf("state %s, want firing while snoozed", state)
+	}
+	expectEvents(t, evaluate(e, rule, 30*time.Minute, 35))
+
+	// Once the snooze ends the suppressed alert is sent as a first
+	// notification, not a reminder.
+	expectEvents(t, evaluate(e, rule, 61*time.Minute, 35), StateFiring)
+}
+
+func TestEngineUnsnoozeNotifiesAtOnce(t *testing.T) {
+	e := NewEngine()
+	rule := hotRule()
+	rule.ForSeconds = 0
+
+	expectEvents(t, evaluate(e, rule, 0, 25))
+	e.Snooze(rule.ID, start.Add(time.Hour))
+	expectEvents(t, evaluate(e, rule, time.Minute, 35))
+	if err := e.Snooze(rule.ID, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	expectEvents(t, evaluate(e, rule, 2*time.Minute, 35), StateFiring)
+}
+
+func TestEngineDisabledRuleResolves(t *testing.T) {
+	e := NewEngine()
+	rule := hotRule()
+	rule.ForSeconds = 0
+
+	expectEvents(t, evaluate(e, rule, 0, 35), StateFiring)
+	rule.Enabled = false
+	expectEvents(t, evaluate(e, rule, time.Minute, 35), "resolved")
+}
+
+func TestDefaultRelayFailureIgnoresOneMismatch(t *testing.T) {
+	var rule Rule
+	for _, r := range DefaultRules() {
+		if r.Type == RuleRelayFailure {
+			rule = r
+		}
+	}
+	e := NewEngine()
+	observe := func(offset time.Duration, fault string) []Event {
+		return e.Evaluate([]Rule{rule}, Observation{Time: start.Add(offset), RelayFault: fault})
+	}
+
+	// A relay caught mid-switch for one cycle is not a failure.
+	expectEvents(t, observe(0, "pump is false, expected true"))
+	expectEvents(t, observe(10*time.Second, ""))
+
+	expectEvents(t, observe(20*time.Second, "pump is false, expected true"))
+	expectEvents(t, observe(40*time.Second, "pump is false, expected true"))
+	expectEvents(t, observe(50*time.Second, "pump is false, expected true"), StateFiring)
+}
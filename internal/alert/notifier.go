@@ -0,0 +1,187 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultNotifyTimeout = 10 * time.Second
+
+// Notifier delivers alert events to a destination.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, event Event) error
+}
+
+// WebhookConfig posts each event as JSON to URL. MinSeverity filters out
+// less severe events.
+type WebhookConfig struct {
+	URL         string `json:"url"`
+	MinSeverity string `json:"min_severity,omitempty"`
+}
+
+// SMTPConfig mails each event to To through the server at Host:Port.
+// Username enables PLAIN authentication, which net/smtp only allows over
+// TLS or to localhost.
+type SMTPConfig struct {
+	Enabled     bool     `json:"enabled"`
+	Host        string   `json:"host"`
+	Port        int      `json:"port"`
+	Username    string   `json:"username,omitempty"`
+	Password    string   `json:"password,omitempty"`
+	From        string   `json:"from"`
+	To          []string `json:"to"`
+	MinSeverity string   `json:"min_severity,omitempty"`
+}
+
+// NotifierSettings lists the configured notification backends.
+type NotifierSettings struct {
+	Webhooks []WebhookConfig `json:"webhooks"`
+	SMTP     SMTPConfig      `json:"smtp"`
+}
+
+// Validate rejects notifiers that could never deliver.
+func (ns NotifierSettings) Validate() error {
+	for i, webhook := range ns.Webhooks {
+		if !strings.HasPrefix(webhook.URL, "http://") && !strings.HasPrefix(webhook.URL, "https://") {
+			return fmt.Errorf("webhook %d: URL must start with http:// or https://", i)
+		}
+		if _, ok := severityRank[webhook.MinSeverity]; webhook.MinSeverity != "" && !ok {
+			return fmt.Errorf("webhook %d: unknown severity %q", i, webhook.MinSeverity)
+		}
+	}
+	if ns.SMTP.Enabled {
+		if ns.SMTP.Host == "" || ns.SMTP.Port <= 0 || ns.SMTP.From == "" || len(ns.SMTP.To) == 0 {
+			return fmt.Errorf("smtp needs host, port, from and to")
+		}
+		if _, ok := severityRank[ns.SMTP.MinSeverity]; ns.SMTP.MinSeverity != "" && !ok {
+			return fmt.Errorf("smtp: unknown severity %q", ns.SMTP.MinSeverity)
+		}
+	}
+	return nil
+}
+
+// Build creates a notifier for each configured backend.
+func (ns NotifierSettings) Build() []Notifier {
+	notifiers := make([]Notifier, 0, len(ns.Webhooks)+1)
+	for _, webhook := range ns.Webhooks {
+		notifiers = append(notifiers, NewWebhookNotifier(webhook))
+	}
+	if ns.SMTP.Enabled {
+		notifiers = append(notifiers, NewSMTPNotifier(ns.SMTP))
+	}
+	return notifiers
+}
+
+type WebhookNotifier struct {
+	cfg    WebhookConfig
+	client *http.Client
+}
+
+func NewWebhookNotifier(cfg WebhookConfig) *WebhookNotifier {
+	return &WebhookNotifier{cfg: cfg, client: &http.Client{Timeout: defaultNotifyTimeout}}
+}
+
+func (w *WebhookNotifier) Name() string {
+	return "webhook " + w.cfg.URL
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, event Event) error {
+	if !SeverityAtLeast(event.Severity, w.cfg.MinSeverity) {
+		return nil
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+type SMTPNotifier struct {
+	cfg SMTPConfig
+}
+
+func NewSMTPNotifier(cfg SMTPConfig) *SMTPNotifier {
+	return &SMTPNotifier{cfg: cfg}
+}
+
+func (s *SMTPNotifier) Name() string {
+	return "smtp " + s.cfg.Host
+}
+
+func (s *SMTPNotifier) Notify(ctx context.Context, event Event) error {
+	if !SeverityAtLeast(event.Severity, s.cfg.MinSeverity) {
+		return nil
+	}
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+
+	subject := fmt.Sprintf("[terrarium %s] %s %s", event.Severity, event.RuleID, event.State)
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", event.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n\r\nRule: %s (%s)\r\nTime: %s\r\n",
+		event.Message, event.RuleID, event.Type, event.Time.Format(time.RFC3339))
+
+	// net/smtp has no context support; run it aside so ctx bounds the wait.
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, s.cfg.From, s.cfg.To, msg.Bytes())
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Dispatch sends events to every notifier in the background, logging
+// failures.
+func Dispatch(notifiers []Notifier, events []Event) {
+	for _, event := range events {
+		state := event.State
+		if event.Reminder {
+			state = "still " + state
+		}
+		log.Printf("Alert %s %s (%s): %s", event.RuleID, state, event.Severity, event.Message)
+		for _, notifier := range notifiers {
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), defaultNotifyTimeout)
+				defer cancel()
+				if err := notifier.Notify(ctx, event); err != nil {
+					log.Printf("Alert notification via %s failed: %v", notifier.Name(), err)
+				}
+			}()
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package alert
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEvent(severity string) Event {
+	return Event{RuleID: "hot", Type: RuleTemperature, Severity: severity, State: StateFiring,
+		Message: "Temperature 35.0°C above 30.0°C", Time: start}
+}
+
+func TestWebhookNotifierPostsEvent(t *testing.T) {
+	received := make(chan Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s with content type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		var event Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("decoding body: %v", err)
+		}
+		received <- event
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(WebhookConfig{URL: server.URL})
+	if err := notifier.Notify(context.Background(), testEvent(SeverityWarning)); err != nil {
+		t.Fatal(err)
+	}
+	event := <-received
+	if event.RuleID != "hot" || event.State != StateFiring || !event.Time.Equal(start) {
+		t.Fatalf("webhook received %+v", event)
+	}
+}
+
+func TestWebhookNotifierReportsHTTPErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(WebhookConfig{URL: server.URL})
+	err := notifier.Notify(context.Background(), testEvent(SeverityWarning))
+	if err == nil || !strings.Contains(err.Error(), "502") {
+		t.Fatalf("got %v, want the 502 status", err)
+	}
+}
+
+func TestWebhookNotifierFiltersSeverity(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("an info event should not reach a critical-only webhook")
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(WebhookConfig{URL: server.URL, MinSeverity: SeverityCritical})
+	if err := notifier.Notify(context.Background(), testEvent(SeverityInfo)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP accepts one message and sends its envelope and data on the
+// returned channel.
+func fakeSMTP(t *testing.T) (host string, port int, messages <-chan smtpMessage) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	out := make(chan smtpMessage, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		var msg smtpMessage
+		reply("220 localhost fake SMTP")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			command := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "MAIL FROM:"):
+				msg.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(command, "RCPT TO:"):
+				msg.to = append(msg.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					dataLine, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if dataLine == ".\r\n" {
+						break
+					}
+					data.WriteString(dataLine)
+				}
+				msg.data = data.String()
+				reply("250 OK")
+			case command == "QUIT":
+				reply("221 Bye")
+				out <- msg
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, out
+}
+
+func TestSMTPNotifierSendsMail(t *testing.T) {
+	host, port, messages := fakeSMTP(t)
+	notifier := NewSMTPNotifier(SMTPConfig{
+		Enabled: true,
+		Host:    host,
+		Port:    port,
+		From:    "terrarium@example.com",
+		To:      []string{"keeper@example.com", "vet@example.com"},
+	})
+
+	if err := notifier.Notify(context.Background(), testEvent(SeverityCritical)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-messages:
+		if msg.from != "terrarium@example.com" || len(msg.to) != 2 || msg.to[1] != "vet@example.com" {
+			t.Fatalf("envelope from %q to %v", msg.from, msg.to)
+		}
+		if !strings.Contains(msg.data, "Subject: [terrarium critical] hot firing") {
+			t.Fatalf("missing subject in\n%s", msg.data)
+		}
+		if !strings.Contains(msg.data, "Temperature 35.0°C above 30.0°C") {
+			t.Fatalf("missing message in\n%s", msg.data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mail delivered")
+	}
+}
+
+func TestSMTPNotifierReportsUnreachableServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	notifier := NewSMTPNotifier(SMTPConfig{Enabled: true, Host: "127.0.0.1", Port: port,
+		From: "terrarium@example.com", To: []string{"keeper@example.com"}})
+	if err := notifier.Notify(context.Background(), testEvent(SeverityCritical)); err == nil {
+		t.Fatal("sending to a closed port should fail")
+	}
+}
+
+func TestNotifierSettingsValidate(t *testing.T) {
+	for name, settings := range map[string]NotifierSettings{
+		"bad url":      {Webhooks: []WebhookConfig{{URL: "ftp://example.com"}}},
+		"bad severity": {Webhooks: []WebhookConfig{{URL: "http://example.com", MinSeverity: "loud"}}},
+		"smtp no to":   {SMTP: SMTPConfig{Enabled: true, Host: "mail", Port: 25, From: "a@b"}},
+	} {
+		if err := settings.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	ok := NotifierSettings{
+		Webhooks: []WebhookConfig{{URL: "https://example.com/hook"}},
+		SMTP:     SMTPConfig{Enabled: true, Host: "mail", Port: 25, From: "a@b", To: []string{"c@d"}},
+	}
+	if err := ok.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(ok.Build()); n != 2 {
+		t.Fatalf("built %d notifiers, want 2", n)
+	}
+}
@@ -0,0 +1,69 @@
+// Package astro computes solar events locally, without network access.
+package astro
+
+import (
+	"math"
+	"time"
+)
+
+type DayType int
+
+const (
+	NormalDay DayType = iota
+	PolarDay
+	PolarNight
+)
+
+const (
+	julianUnixEpoch = 2440587.5
+	julian2000      = 2451545.0
+	degToRad        = math.Pi / 180
+	// Apparent sunrise: the upper limb touches the horizon, including
+	// atmospheric refraction.
+	sunriseAltitude = -0.833
+)
+
+func toJulian(t time.Time) float64 {
+	return float64(t.Unix())/86400 + julianUnixEpoch
+}
+
+func fromJulian(jd float64) time.Time {
+	return time.Unix(int64(math.Round((jd-julianUnixEpoch)*86400)), 0)
+}
+
+// SunTimes returns sunrise and sunset on the calendar day of date (in
+// date's location) for a position in degrees, north and east positive. It
+// follows the sunrise equation used by NOAA's low-precision algorithm,
+// accurate to about a minute at moderate latitudes.
+func SunTimes(date time.Time, latitude, longitude float64) (sunrise, sunset time.Time, dayType DayType) {
+	y, m, d := date.Date()
+	noonUTC := time.Date(y, m, d, 12, 0, 0, 0, time.UTC)
+	n := math.Round(toJulian(noonUTC) - julian2000)
+
+	meanNoon := n - longitude/360
+	anomaly := math.Mod(357.5291+0.98560028*meanNoon, 360)
+	mRad := anomaly * degToRad
+	center := 1.9148*math.Sin(mRad) + 0.02*math.Sin(2*mRad) + 0.0003*math.Sin(3*mRad)
+	eclipticLong := math.Mod(anomaly+center+180+102.9372, 360)
+	lRad := eclipticLong * degToRad
+	transit := julian2000 + meanNoon + 0.0053*math.Sin(mRad) - 0.0069*math.Sin(2*lRad)
+
+	sinDecl := math.Sin(lRad) * math.Sin(23.4397*degToRad)
+	cosDecl := math.Cos(math.Asin(sinDecl))
+	latRad := latitude * degToRad
+	cosHour := (math.Sin(sunriseAltitude*degToRad) - math.Sin(latRad)*sinDecl) / (math.Cos(latRad) * cosDecl)
+
+	loc := date.Location()
+	noon := fromJulian(transit).In(loc)
+	switch {
+	case cosHour > 1:
+		return noon, noon, PolarNight
+	case cosHour < -1:
+		return noon, noon, PolarDay
+	}
+
+	hourAngle := math.Acos(cosHour) / degToRad
+	sunrise = fromJulian(transit - hourAngle/360).In(loc)
+	sunset = fromJulian(transit + hourAngle/360).In(loc)
+	return sunrise, sunset, NormalDay
+}
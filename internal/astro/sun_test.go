@@ -0,0 +1,77 @@
+package astro
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	bst  = time.FixedZone("BST", 3600)
+	aedt = time.FixedZone("AEDT", 11*3600)
+)
+
+func within(got, want time.Time, tolerance time.Duration) bool {
+	diff := got.Sub(want)
+	return diff >= -tolerance && diff <= tolerance
+}
+
+// Reference times are from published almanac tables, rounded to the minute.
+func TestSunTimes(t *testing.T) {
+	tests := []struct {
+		name                string
+		date                time.Time
+		latitude, longitude float64
+		sunrise, sunset     time.Time
+	}{
+		{"London summer solstice", time.Date(2026, 6, 21, 0, 0, 0, 0, bst), 51.5074, -0.1278,
+			time.Date(2026, 6, 21, 4, 43, 0, 0, bst), time.Date(2026, 6, 21, 21, 21, 0, 0, bst)},
+		{"Sydney December solstice", time.Date(2026, 12, 21, 0, 0, 0, 0, aedt), -33.8688, 151.2093,
+			time.Date(2026, 12, 21, 5, 41, 0, 0, aedt), time.Date(2026, 12, 21, 20, 5, 0, 0, aedt)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sunrise, sunset, dayType := SunTimes(tt.date, tt.latitude, tt.longitude)
+			if dayType != NormalDay {
+				t.Fatalf("day type %v, want NormalDay", dayType)
+			}
+			if !within(sunrise, tt.sunrise, 2*time.Minute) {
+				t.Errorf("sunrise %v, want %v", sunrise, tt.sunrise)
+			}
+			if !within(sunset, tt.sunset, 2*time.Minute) {
+				t.Errorf("sunset %v, want %v", sunset, tt.sunset)
+			}
+			if sunrise.Location() != tt.date.Location() {
+				t.Errorf("sunrise in %v, want %v", sunrise.Location(), tt.date.Location())
+			}
+		})
+	}
+}
+
+func TestSunTimesPolar(t *testing.T) {
+	// Tromsø: midnight sun in June, polar night in December. Both report
+	// solar noon, about 10:44 UTC at that longitude.
+	const latitude, longitude = 69.6492, 18.9553
+	tests := []struct {
+		name string
+		date time.Time
+		want DayType
+	}{
+		{"polar day", time.Date(2026, 6, 21, 0, 0, 0, 0, time.UTC), PolarDay},
+		{"polar night", time.Date(2026, 12, 21, 0, 0, 0, 0, time.UTC), PolarNight},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sunrise, sunset, dayType := SunTimes(tt.date, latitude, longitude)
+			if dayType != tt.want {
+				t.Fatalf("day type %v, want %v", dayType, tt.want)
+			}
+			if !sunrise.Equal(sunset) {
+				t.Errorf("sunrise %v and sunset %v differ, want both at solar noon", sunrise, sunset)
+			}
+			noon := time.Date(2026, tt.date.Month(), 21, 10, 44, 0, 0, time.UTC)
+			if !within(sunrise, noon, 5*time.Minute) {
+				t.Errorf("solar noon %v, want about %v", sunrise, noon)
+			}
+		})
+	}
+}
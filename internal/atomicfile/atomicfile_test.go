@@ -0,0 +1,67 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string // written first when not empty
+		perm     os.FileMode
+	}{
+		{"new file", "", 0o600},
+		{"replace", "old contents that are longer", 0o644},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "sub", "file.json")
+			if tt.existing != "" {
+				if err := Write(path, []byte(tt.existing), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := Write(path, []byte("new"), tt.perm); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil || string(data) != "new" {
+				t.Fatalf("read %q, %v; want \"new\"", data, err)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != tt.perm {
+				t.Errorf("mode %v, want %v", info.Mode().Perm(), tt.perm)
+			}
+			if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+				t.Errorf("%d files in the directory, want only the target", len(entries))
+			}
+		})
+	}
+}
+
+func TestWriteCleansUpOnFailure(t *testing.T) {
+	dir := t.TempDir()
+
+	// A non-empty directory in the way makes the rename fail after the
+	// temp file was written.
+	blocked := filepath.Join(dir, "blocked")
+	if err := os.MkdirAll(filepath.Join(blocked, "child"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(blocked, []byte("new"), 0o600); err == nil {
+		t.Fatal("expected an error replacing a directory")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d entries in the directory, want the temp file removed", len(entries))
+	}
+	if _, err := os.Stat(filepath.Join(blocked, "child")); err != nil {
+		t.Errorf("target was modified: %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+// Package auth holds API users and tokens, verifies credentials and keeps
+// login sessions.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// RoleRead may call every read-only route.
+	RoleRead = "read"
+	// RoleAdmin may also change settings and drive relays.
+	RoleAdmin = "admin"
+)
+
+const (
+	MinPasswordLength = 8
+	tokenPrefix       = "trm_"
+)
+
+var roleRank = map[string]int{RoleRead: 1, RoleAdmin: 2}
+
+// dummyHash is compared against when a login names an unknown user, so
+// the response time does not reveal which usernames exist.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("terrarium-dummy-password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// User logs in with a password; only its bcrypt hash is stored.
+type User struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash,omitempty"`
+	Role         string `json:"role"`
+}
+
+// Token is an API token for scripts and scrapers. Tokens are random, so a
+// SHA-256 hash is enough to store them and is cheap to check per request.
+type Token struct {
+	Name    string    `json:"name"`
+	Hash    string    `json:"hash,omitempty"`
+	Role    string    `json:"role"`
+	Created time.Time `json:"created"`
+}
+
+// Settings configures API access. With Enabled unset every request is
+// treated as admin, as before authentication existed.
+type Settings struct {
+	Enabled bool    `json:"enabled"`
+	Users   []User  `json:"users"`
+	Tokens  []Token `json:"tokens"`
+	// AllowedOrigins lists the origins allowed to call the API from a
+	// browser, e.g. "https://dashboard.local". "*" allows any origin
+	// without credentials. Empty means same-origin only.
+	AllowedOrigins []string `json:"allowed_origins"`
+	SessionHours   int      `json:"session_hours"`
+}
+
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// RoleAllows reports whether have grants at least the rights of need.
+func RoleAllows(have, need string) bool {
+	return roleRank[have] >= roleRank[need] && roleRank[need] > 0
+}
+
+// Validate rejects settings that are malformed or would lock every admin
+// out.
+func (s Settings) Validate() error {
+	usernames := make(map[string]bool, len(s.Users))
+	hasAdmin := false
+	for _, user := range s.Users {
+		if user.Username == "" {
+			return fmt.Errorf("user without a username")
+		}
+		if usernames[user.Username] {
+			return fmt.Errorf("duplicate user %q", user.Username)
+		}
+		usernames[user.Username] = true
+		if !ValidRole(user.Role) {
+			return fmt.Errorf("user %q: unknown role %q", user.Username, user.Role)
+		}
+		if user.PasswordHash == "" {
+			return fmt.Errorf("user %q has no password", user.Username)
+		}
+		hasAdmin = hasAdmin || user.Role == RoleAdmin
+	}
+
+	names := make(map[string]bool, len(s.Tokens))
+	for _, token := range s.Tokens {
+		if token.Name == "" {
+			return fmt.Errorf("token without a name")
+		}
+		if names[token.Name] {
+			return fmt.Errorf("duplicate token %q", token.Name)
+		}
+		names[token.Name] = true
+		if !ValidRole(token.Role) {
+			return fmt.Errorf("token %q: unknown role %q", token.Name, token.Role)
+		}
+		if token.Hash == "" {
+			return fmt.Errorf("token %q has no hash", token.Name)
+		}
+		hasAdmin = hasAdmin || token.Role == RoleAdmin
+	}
+
+	if s.Enabled && !hasAdmin {
+		return fmt.Errorf("authentication needs an admin user or token before it can be enabled")
+	}
+
+	for _, origin := range s.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			(u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			return fmt.Errorf("origin %q must look like https://host[:port]", origin)
+		}
+	}
+	if s.SessionHours < 0 {
+		return fmt.Errorf("session_hours must not be negative")
+	}
+	return nil
+}
+
+// Redacted returns a copy without password and token hashes.
+func (s Settings) Redacted() Settings {
+	out := s
+	out.Users = make([]User, len(s.Users))
+	for i, user := range s.Users {
+		user.PasswordHash = ""
+		out.Users[i] = user
+	}
+	out.Tokens = make([]Token, len(s.Tokens))
+	for i, token := range s.Tokens {
+		token.Hash = ""
+		out.Tokens[i] = token
+	}
+	out.AllowedOrigins = slices.Clone(s.AllowedOrigins)
+	return out
+}
+
+// OriginAllowed reports whether a browser on origin may call the API, and
+// whether it may send credentials.
+func (s Settings) OriginAllowed(origin string) (allowed, credentials bool) {
+	origin = strings.TrimSuffix(origin, "/")
+	for _, allowedOrigin := range s.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowedOrigin, "/"), origin) {
+			return true, true
+		}
+		if allowedOrigin == "*" {
+			allowed = true
+		}
+	}
+	return allowed, false
+}
+
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Login checks a username and password.
+func (s Settings) Login(username, password string) (User, bool) {
+	for _, user := range s.Users {
+		if user.Username == username {
+			err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+			return user, err == nil
+		}
+	}
+	bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+	return User{}, false
+}
+
+// NewToken returns a fresh token and the hash to store for it.
+func NewToken() (plain, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	plain = tokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return plain, HashToken(plain), nil
+}
+
+func HashToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// LookupToken finds the token matching plain.
+func (s Settings) LookupToken(plain string) (Token, bool) {
+	hash := []byte(HashToken(plain))
+	for _, token := range s.Tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(token.Hash)) == 1 {
+			return token, true
+		}
+	}
+	return Token{}, false
+}
+
+// User returns the user named username.
+func (s Settings) User(username string) (User, bool) {
+	for _, user := range s.Users {
+		if user.Username == username {
+			return user, true
+		}
+	}
+	return User{}, false
+}
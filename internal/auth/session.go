@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+const DefaultSessionTTL = 24 * time.Hour
+
+// Session is a browser login. Sessions live in memory, so a restart logs
+// everyone out.
+type Session struct {
+	ID       string
+	Username string
+	Expires  time.Time
+}
+
+type Sessions struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+func NewSessions() *Sessions {
+	return &Sessions{sessions: make(map[string]Session)}
+}
+
+func (s *Sessions) Create(username string, ttl time.Duration, now time.Time) (Session, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return Session{}, err
+	}
+	session := Session{
+		ID:       base64.RawURLEncoding.EncodeToString(secret),
+		Username: username,
+		Expires:  now.Add(ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, existing := range s.sessions {
+		if !now.Before(existing.Expires) {
+			delete(s.sessions, id)
+		}
+	}
+	s.sessions[session.ID] = session
+	return session, nil
+}
+
+// Get returns the live session with id.
+func (s *Sessions) Get(id string, now time.Time) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return Session{}, false
+	}
+	if !now.Before(session.Expires) {
+		delete(s.sessions, id)
+		return Session{}, false
+	}
+	return session, true
+}
+
+func (s *Sessions) Delete(id string) {
+	s.mu.Lock()
+	delete(s.sessions, id)
+	s.mu.Unlock()
+}
+
+// DeleteUser ends every session of username, e.g. after a password change.
+func (s *Sessions) DeleteUser(username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, session := range s.sessions {
+		if session.Username == username {
+			delete(s.sessions, id)
+		}
+	}
+}
@@ -36,15 +36,19 @@ func NewOLEDDisplay() (*OLEDDisplay, error) {
 		return nil, fmt.Errorf("failed to open I2C bus: %v", err)
 	}
 
-	display := &OLEDDisplay{
+	return NewOLEDDisplayOnBus(bus), nil
+}
+
+// NewOLEDDisplayOnBus creates a display on an I2C bus that is already open,
+// so it can share the bus with I2C sensors.
+func NewOLEDDisplayOnBus(bus i2c.Bus) *OLEDDisplay {
+	return &OLEDDisplay{
 		bus:         bus,
 		address:     SSD1306_I2C_ADDRESS,
 		width:       DISPLAY_WIDTH,
 		height:      DISPLAY_HEIGHT,
 		initialized: false,
 	}
-
-	return display, nil
 }
 
 func (oled *OLEDDisplay) Init() error {
@@ -0,0 +1,43 @@
+package gpio
+
+import "log"
+
+// LoggingRelays wraps another driver and logs every switch request.
+type LoggingRelays struct {
+	driver RelayDriver
+	prefix string
+}
+
+func NewLoggingRelays(driver RelayDriver, prefix string) *LoggingRelays {
+	return &LoggingRelays{driver: driver, prefix: prefix}
+}
+
+func (lr *LoggingRelays) logSwitch(name string, state bool, err error) error {
+	if err != nil {
+		log.Printf("[%s] %s -> %v failed: %v", lr.prefix, name, state, err)
+	} else {
+		log.Printf("[%s] %s -> %v", lr.prefix, name, state)
+	}
+	return err
+}
+
+func (lr *LoggingRelays) SetLight(state bool) error {
+	return lr.logSwitch("light", state, lr.driver.SetLight(state))
+}
+
+func (lr *LoggingRelays) SetHeater(state bool) error {
+	return lr.logSwitch("heater", state, lr.driver.SetHeater(state))
+}
+
+func (lr *LoggingRelays) SetPump(state bool) error {
+	return lr.logSwitch("pump", state, lr.driver.SetPump(state))
+}
+
+func (lr *LoggingRelays) States() RelayStates {
+	return lr.driver.States()
+}
+
+func (lr *LoggingRelays) Shutdown() {
+	log.Printf("[%s] shutdown", lr.prefix)
+	lr.driver.Shutdown()
+}
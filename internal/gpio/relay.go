@@ -3,89 +3,174 @@ package gpio
 import (
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/gpio/gpioreg"
 	"periph.io/x/host/v3"
-	"periph.io/x/host/v3/rpi"
 )
 
+// RelayDriver switches the terrarium relays. Implementations report the
+// state they actually applied so callers can verify a switch took effect.
+type RelayDriver interface {
+	SetLight(state bool) error
+	SetHeater(state bool) error
+	SetPump(state bool) error
+	States() RelayStates
+	Shutdown()
+}
+
+type RelayStates struct {
+	Light  bool `json:"light"`
+	Heater bool `json:"heater"`
+	Pump   bool `json:"pump"`
+}
+
+// RelayChannel maps a relay to a GPIO by name. ActiveLow boards energize
+// the relay when the pin is Low. SafeOn keeps the relay energized at boot
+// and shutdown instead of the usual de-energized safe state.
+type RelayChannel struct {
+	Pin       string `json:"pin"`
+	ActiveLow bool   `json:"active_low"`
+	SafeOn    bool   `json:"safe_on"`
+}
+
+type PinConfig struct {
+	Light  RelayChannel `json:"light"`
+	Heater RelayChannel `json:"heater"`
+	Pump   RelayChannel `json:"pump"`
+	DHT22  string       `json:"dht22"`
+}
+
+func DefaultPinConfig() PinConfig {
+	return PinConfig{
+		Light:  RelayChannel{Pin: "GPIO17"},
+		Heater: RelayChannel{Pin: "GPIO27"},
+		Pump:   RelayChannel{Pin: "GPIO22"},
+		DHT22:  "GPIO4",
+	}
+}
+
+// maxBCMPin is the highest GPIO on the Raspberry Pi 40-pin header.
+const maxBCMPin = 27
+
+// Validate checks that every pin is a header GPIO by BCM name, e.g.
+// "GPIO17", and that no two functions share a pin.
+func (cfg PinConfig) Validate() error {
+	pins := []struct{ name, pin string }{
+		{"light", cfg.Light.Pin},
+		{"heater", cfg.Heater.Pin},
+		{"pump", cfg.Pump.Pin},
+		{"dht22", cfg.DHT22},
+	}
+	used := make(map[string]string, len(pins))
+	for _, p := range pins {
+		number, ok := strings.CutPrefix(p.pin, "GPIO")
+		n, err := strconv.Atoi(number)
+		if !ok || err != nil || n < 0 || n > maxBCMPin || number != strconv.Itoa(n) {
+			return fmt.Errorf("%s pin %q must be GPIO0 to GPIO%d", p.name, p.pin, maxBCMPin)
+		}
+		if other, ok := used[number]; ok {
+			return fmt.Errorf("%s and %s both use GPIO%s", other, p.name, number)
+		}
+		used[number] = p.name
+	}
+	return nil
+}
+
+type relayPin struct {
+	pin     gpio.PinIO
+	channel RelayChannel
+}
+
+func (rp relayPin) level(state bool) gpio.Level {
+	return gpio.Level(state != rp.channel.ActiveLow)
+}
+
+func (rp relayPin) set(state bool) error {
+	return rp.pin.Out(rp.level(state))
+}
+
+func (rp relayPin) state() bool {
+	return (rp.pin.Read() == gpio.High) != rp.channel.ActiveLow
+}
+
+func (rp relayPin) safe() error {
+	return rp.set(rp.channel.SafeOn)
+}
+
 type RelayController struct {
-	pinLight  gpio.PinOut
-	pinHeater gpio.PinOut
-	pinPump   gpio.PinOut
-	pinDHT22  gpio.PinIO
+	light    relayPin
+	heater   relayPin
+	pump     relayPin
+	pinDHT22 gpio.PinIO
 }
 
-func NewRelayController() (*RelayController, error) {
+func NewRelayController(cfg PinConfig) (*RelayController, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 	if _, err := host.Init(); err != nil {
 		return nil, fmt.Errorf("periph initialization error: %v", err)
 	}
 
-	pinLight := rpi.P1_11
-	pinHeater := rpi.P1_13
-	pinPump := rpi.P1_15
-	pinDHT22 := rpi.P1_7
-
-	if err := pinLight.Out(gpio.Low); err != nil {
-		return nil, fmt.Errorf("light pin setup error: %v", err)
+	rc := &RelayController{}
+	channels := []struct {
+		name    string
+		channel RelayChannel
+		target  *relayPin
+	}{
+		{"light", cfg.Light, &rc.light},
+		{"heater", cfg.Heater, &rc.heater},
+		{"pump", cfg.Pump, &rc.pump},
 	}
-	if err := pinHeater.Out(gpio.Low); err != nil {
-		return nil, fmt.Errorf("heater pin setup error: %v", err)
+
+	// Drive every relay to its safe level before anything else touches
+	// the hardware, so active-low boards do not energize at boot.
+	for _, ch := range channels {
+		pin := gpioreg.ByName(ch.channel.Pin)
+		if pin == nil {
+			return nil, fmt.Errorf("%s pin %q not found", ch.name, ch.channel.Pin)
+		}
+		*ch.target = relayPin{pin: pin, channel: ch.channel}
+		if err := ch.target.safe(); err != nil {
+			return nil, fmt.Errorf("%s pin setup error: %v", ch.name, err)
+		}
 	}
-	if err := pinPump.Out(gpio.Low); err != nil {
-		return nil, fmt.Errorf("pump pin setup error: %v", err)
+
+	rc.pinDHT22 = gpioreg.ByName(cfg.DHT22)
+	if rc.pinDHT22 == nil {
+		return nil, fmt.Errorf("DHT22 pin %q not found", cfg.DHT22)
 	}
 
 	log.Println("GPIO initialized successfully")
-	log.Printf("Pins: Light=%v, Heater=%v, Pump=%v, DHT22=%v",
-		pinLight, pinHeater, pinPump, pinDHT22)
+	log.Printf("Pins: Light=%v (active-low %v), Heater=%v (active-low %v), Pump=%v (active-low %v), DHT22=%v",
+		rc.light.pin, cfg.Light.ActiveLow, rc.heater.pin, cfg.Heater.ActiveLow,
+		rc.pump.pin, cfg.Pump.ActiveLow, rc.pinDHT22)
 
-	return &RelayController{
-		pinLight:  pinLight,
-		pinHeater: pinHeater,
-		pinPump:   pinPump,
-		pinDHT22:  pinDHT22,
-	}, nil
+	return rc, nil
 }
 
 func (rc *RelayController) SetLight(state bool) error {
-	if state {
-		if err := rc.pinLight.Out(gpio.High); err != nil {
-			return err
-		}
-	} else {
-		if err := rc.pinLight.Out(gpio.Low); err != nil {
-			return err
-		}
-	}
-	return nil
+	return rc.light.set(state)
 }
 
 func (rc *RelayController) SetHeater(state bool) error {
-	if state {
-		if err := rc.pinHeater.Out(gpio.High); err != nil {
-			return err
-		}
-	} else {
-		if err := rc.pinHeater.Out(gpio.Low); err != nil {
-			return err
-		}
-	}
-	return nil
+	return rc.heater.set(state)
 }
 
 func (rc *RelayController) SetPump(state bool) error {
-	if state {
-		if err := rc.pinPump.Out(gpio.High); err != nil {
-			return err
-		}
-	} else {
-		if err := rc.pinPump.Out(gpio.Low); err != nil {
-			return err
-		}
+	return rc.pump.set(state)
+}
+
+func (rc *RelayController) States() RelayStates {
+	return RelayStates{
+		Light:  rc.light.state(),
+		Heater: rc.heater.state(),
+		Pump:   rc.pump.state(),
 	}
-	return nil
 }
 
 func (rc *RelayController) GetDHT22Pin() gpio.PinIO {
@@ -93,9 +178,11 @@ func (rc *RelayController) GetDHT22Pin() gpio.PinIO {
 }
 
 func (rc *RelayController) Shutdown() {
-	log.Println("Turning off all relays...")
-	rc.SetLight(false)
-	rc.SetHeater(false)
-	rc.SetPump(false)
+	log.Println("Returning all relays to their safe state...")
+	for _, rp := range []relayPin{rc.light, rc.heater, rc.pump} {
+		if err := rp.safe(); err != nil {
+			log.Printf("Error setting %v to safe state: %v", rp.pin, err)
+		}
+	}
 	time.Sleep(100 * time.Millisecond)
 }
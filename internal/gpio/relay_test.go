@@ -0,0 +1,45 @@
+package gpio
+
+import "testing"
+
+func TestPinConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(*PinConfig)
+		wantErr string
+	}{
+		{"defaults", func(*PinConfig) {}, ""},
+		{"highest header pin", func(c *PinConfig) { c.Pump.Pin = "GPIO27"; c.Heater.Pin = "GPIO26" }, ""},
+		{"empty pin", func(c *PinConfig) { c.Light.Pin = "" }, `light pin "" must be GPIO0 to GPIO27`},
+		{"unknown name", func(c *PinConfig) { c.Heater.Pin = "PIN17" }, `heater pin "PIN17" must be GPIO0 to GPIO27`},
+		{"lower case", func(c *PinConfig) { c.Light.Pin = "gpio5" }, `light pin "gpio5" must be GPIO0 to GPIO27`},
+		{"out of range", func(c *PinConfig) { c.Pump.Pin = "GPIO28" }, `pump pin "GPIO28" must be GPIO0 to GPIO27`},
+		{"leading zero", func(c *PinConfig) { c.Pump.Pin = "GPIO017" }, `pump pin "GPIO017" must be GPIO0 to GPIO27`},
+		{"missing DHT22 pin", func(c *PinConfig) { c.DHT22 = "" }, `dht22 pin "" must be GPIO0 to GPIO27`},
+		{"shared relay pin", func(c *PinConfig) { c.Pump.Pin = c.Light.Pin }, "light and pump both use GPIO17"},
+		{"relay on the DHT22 pin", func(c *PinConfig) { c.Heater.Pin = c.DHT22 }, "heater and dht22 both use GPIO4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultPinConfig()
+			tt.change(&cfg)
+			err := cfg.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewRelayControllerRejectsInvalidPins(t *testing.T) {
+	cfg := DefaultPinConfig()
+	cfg.Pump.Pin = cfg.Heater.Pin
+
+	// Validation runs before any hardware is touched.
+	if _, err := NewRelayController(cfg); err == nil || err.Error() != "heater and pump both use GPIO27" {
+		t.Fatalf("error %v, want the pin conflict", err)
+	}
+}
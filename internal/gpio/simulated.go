@@ -0,0 +1,47 @@
+package gpio
+
+import "sync"
+
+// SimulatedRelays keeps relay states in memory for running without GPIO
+// hardware.
+type SimulatedRelays struct {
+	mu     sync.RWMutex
+	states RelayStates
+}
+
+func NewSimulatedRelays() *SimulatedRelays {
+	return &SimulatedRelays{}
+}
+
+func (sr *SimulatedRelays) SetLight(state bool) error {
+	sr.mu.Lock()
+	sr.states.Light = state
+	sr.mu.Unlock()
+	return nil
+}
+
+func (sr *SimulatedRelays) SetHeater(state bool) error {
+	sr.mu.Lock()
+	sr.states.Heater = state
+	sr.mu.Unlock()
+	return nil
+}
+
+func (sr *SimulatedRelays) SetPump(state bool) error {
+	sr.mu.Lock()
+	sr.states.Pump = state
+	sr.mu.Unlock()
+	return nil
+}
+
+func (sr *SimulatedRelays) States() RelayStates {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+	return sr.states
+}
+
+func (sr *SimulatedRelays) Shutdown() {
+	sr.mu.Lock()
+	sr.states = RelayStates{}
+	sr.mu.Unlock()
+}
@@ -0,0 +1,176 @@
+// Package metrics writes the Prometheus text exposition format and keeps
+// the histogram type the controller records into.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Labels are written sorted by name.
+type Labels map[string]string
+
+// Writer emits metric families; each family's HELP and TYPE lines are
+// written once, before its first sample.
+type Writer struct {
+	w    io.Writer
+	seen map[string]bool
+	err  error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, seen: make(map[string]bool)}
+}
+
+// Err returns the first write error.
+func (mw *Writer) Err() error {
+	return mw.err
+}
+
+func (mw *Writer) printf(format string, args ...any) {
+	if mw.err != nil {
+		return
+	}
+	_, mw.err = fmt.Fprintf(mw.w, format, args...)
+}
+
+func (mw *Writer) header(name, help, metricType string) {
+	if mw.seen[name] {
+		return
+	}
+	mw.seen[name] = true
+	mw.printf("# HELP %s %s\n", name, escapeHelp(help))
+	mw.printf("# TYPE %s %s\n", name, metricType)
+}
+
+func (mw *Writer) sample(name string, labels Labels, value float64) {
+	mw.printf("%s%s %s\n", name, formatLabels(labels), formatValue(value))
+}
+
+func (mw *Writer) Gauge(name, help string, labels Labels, value float64) {
+	mw.header(name, help, "gauge")
+	mw.sample(name, labels, value)
+}
+
+func (mw *Writer) Counter(name, help string, labels Labels, value float64) {
+	mw.header(name, help, "counter")
+	mw.sample(name, labels, value)
+}
+
+func (mw *Writer) Histogram(name, help string, labels Labels, h HistogramSnapshot) {
+	mw.header(name, help, "histogram")
+	var cumulative uint64
+	for i, bound := range h.Buckets {
+		cumulative += h.Counts[i]
+		mw.sample(name+"_bucket", withLabel(labels, "le", formatValue(bound)), float64(cumulative))
+	}
+	mw.sample(name+"_bucket", withLabel(labels, "le", "+Inf"), float64(h.Count))
+	mw.sample(name+"_sum", labels, h.Sum)
+	mw.sample(name+"_count", labels, float64(h.Count))
+}
+
+func withLabel(labels Labels, name, value string) Labels {
+	merged := make(Labels, len(labels)+1)
+	for k, v := range labels {
+		merged[k] = v
+	}
+	merged[name] = value
+	return merged
+}
+
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", name, escapeLabel(labels[name]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// Histogram counts observations into fixed upper-bound buckets.
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// HistogramSnapshot holds per-bucket (not cumulative) counts.
+type HistogramSnapshot struct {
+	Buckets []float64
+	Counts  []uint64
+	Sum     float64
+	Count   uint64
+}
+
+// NewHistogram takes bucket upper bounds in increasing order.
+func NewHistogram(buckets ...float64) *Histogram {
+	return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sum += v
+	h.count++
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		h.counts[i]++
+	}
+}
+
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return HistogramSnapshot{
+		Buckets: append([]float64(nil), h.buckets...),
+		Counts:  append([]uint64(nil), h.counts...),
+		Sum:     h.sum,
+		Count:   h.count,
+	}
+}
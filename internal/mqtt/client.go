@@ -0,0 +1,275 @@
+// Package mqtt is a small MQTT 3.1.1 client: QoS 0 publish and subscribe,
+// a last will, keepalive pings and automatic reconnection with backoff.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+var ErrNotConnected = errors.New("mqtt: not connected")
+
+const (
+	dialTimeout  = 10 * time.Second
+	writeTimeout = 10 * time.Second
+)
+
+type Message struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+// Options configure a client. Broker is a tcp://, mqtt://, ssl:// or
+// tls:// URL, or a bare host:port for plain TCP. OnConnect runs after every
+// successful (re)connection, before incoming messages are delivered to
+// OnMessage; clean sessions mean subscriptions must be renewed there.
+type Options struct {
+	Broker     string
+	ClientID   string
+	Username   string
+	Password   string
+	KeepAlive  time.Duration
+	Will       *Message
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	OnConnect  func(*Client)
+	OnMessage  func(Message)
+}
+
+type Client struct {
+	opts Options
+
+	mu     sync.Mutex
+	conn   net.Conn
+	nextID uint16
+}
+
+func NewClient(opts Options) *Client {
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = 60 * time.Second
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = time.Second
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = 2 * time.Minute
+	}
+	return &Client{opts: opts}
+}
+
+func (c *Client) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn != nil
+}
+
+// Run connects and keeps the connection up until ctx is cancelled,
+// retrying with exponential backoff after every failure.
+func (c *Client) Run(ctx context.Context) {
+	backoff := c.opts.MinBackoff
+	for {
+		connected, err := c.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = c.opts.MinBackoff
+		}
+		log.Printf("MQTT connection to %s lost: %v; retrying in %v", c.opts.Broker, err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, c.opts.MaxBackoff)
+	}
+}
+
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	address, useTLS := c.opts.Broker, false
+	if u, err := url.Parse(c.opts.Broker); err == nil && u.Host != "" {
+		address = u.Host
+		switch u.Scheme {
+		case "tcp", "mqtt":
+		case "ssl", "tls", "mqtts":
+			useTLS = true
+		default:
+			return nil, fmt.Errorf("unsupported broker scheme %q", u.Scheme)
+		}
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		port := "1883"
+		if useTLS {
+			port = "8883"
+		}
+		address = net.JoinHostPort(address, port)
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	if useTLS {
+		host, _, _ := net.SplitHostPort(address)
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}
+		return tlsDialer.DialContext(ctx, "tcp", address)
+	}
+	return dialer.DialContext(ctx, "tcp", address)
+}
+
+// session runs one connection until it fails or ctx ends, reporting
+// whether the broker accepted the connection.
+func (c *Client) session(ctx context.Context) (bool, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	connect, err := connectPacket(c.opts)
+	if err != nil {
+		return false, err
+	}
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	if _, err := conn.Write(connect); err != nil {
+		return false, err
+	}
+	ack, err := readPacket(reader)
+	if err != nil {
+		return false, fmt.Errorf("waiting for CONNACK: %v", err)
+	}
+	if ack.kind != packetConnack || len(ack.body) != 2 {
+		return false, fmt.Errorf("expected CONNACK, got packet type %d", ack.kind)
+	}
+	if code := ack.body[1]; code != 0 {
+		if reason, ok := connackErrors[code]; ok {
+			return false, fmt.Errorf("broker refused connection: %s", reason)
+		}
+		return false, fmt.Errorf("broker refused connection with code %d", code)
+	}
+	conn.SetDeadline(time.Time{})
+	log.Printf("MQTT connected to %s as %s", c.opts.Broker, c.opts.ClientID)
+
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+	}()
+
+	if c.opts.OnConnect != nil {
+		c.opts.OnConnect(c)
+	}
+
+	readErr := make(chan error, 1)
+	go func() { readErr <- c.readLoop(conn, reader) }()
+
+	ping := time.NewTicker(c.opts.KeepAlive / 2)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			c.write(conn, []byte{packetDisconnect << 4, 0})
+			return true, ctx.Err()
+		case err := <-readErr:
+			return true, err
+		case <-ping.C:
+			if err := c.write(conn, []byte{packetPingreq << 4, 0}); err != nil {
+				return true, err
+			}
+		}
+	}
+}
+
+// readLoop handles incoming packets. The read deadline is renewed on every
+// packet; pings every half keepalive guarantee traffic on a healthy link.
+func (c *Client) readLoop(conn net.Conn, reader *bufio.Reader) error {
+	for {
+		conn.SetReadDeadline(time.Now().Add(c.opts.KeepAlive * 3 / 2))
+		p, err := readPacket(reader)
+		if err != nil {
+			return err
+		}
+		switch p.kind {
+		case packetPublish:
+			msg, id, err := parsePublish(p)
+			if err != nil {
+				return err
+			}
+			if qos := (p.flags >> 1) & 0x03; qos == 1 {
+				ack := binary.BigEndian.AppendUint16([]byte{packetPuback << 4, 2}, id)
+				if err := c.write(conn, ack); err != nil {
+					return err
+				}
+			}
+			if c.opts.OnMessage != nil {
+				c.opts.OnMessage(msg)
+			}
+		case packetSuback:
+			for _, code := range p.body[min(2, len(p.body)):] {
+				if code == 0x80 {
+					log.Printf("MQTT broker rejected a subscription")
+				}
+			}
+		case packetPingresp, packetPuback:
+		default:
+			log.Printf("MQTT ignoring packet type %d", p.kind)
+		}
+	}
+}
+
+func (c *Client) write(conn net.Conn, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := conn.Write(data)
+	return err
+}
+
+func (c *Client) send(data []byte) error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return ErrNotConnected
+	}
+	return c.write(conn, data)
+}
+
+// Publish sends a QoS 0 message; it fails with ErrNotConnected while the
+// client is reconnecting.
+func (c *Client) Publish(topic string, payload []byte, retain bool) error {
+	data, err := publishPacket(Message{Topic: topic, Payload: payload, Retain: retain})
+	if err != nil {
+		return err
+	}
+	return c.send(data)
+}
+
+// Subscribe requests QoS 0 delivery for the topic filters.
+func (c *Client) Subscribe(filters ...string) error {
+	c.mu.Lock()
+	c.nextID++
+	if c.nextID == 0 {
+		c.nextID = 1
+	}
+	id := c.nextID
+	c.mu.Unlock()
+
+	data, err := subscribePacket(id, filters)
+	if err != nil {
+		return err
+	}
+	return c.send(data)
+}
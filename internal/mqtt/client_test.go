@@ -0,0 +1,249 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// fakeBroker accepts connections on a local port and hands the n-th one
+// (counting from 0) to handle, recording when each arrived.
+type fakeBroker struct {
+	listener net.Listener
+	accepted chan time.Time
+}
+
+func newFakeBroker(t *testing.T, handle func(n int, conn net.Conn, r *bufio.Reader)) *fakeBroker {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBroker{listener: listener, accepted: make(chan time.Time, 100)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for n := 0; ; n++ {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			b.accepted <- time.Now()
+			go func(n int) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if p, err := readPacket(r); err != nil || p.kind != packetConnect {
+					return
+				}
+				handle(n, conn, r)
+			}(n)
+		}
+	}()
+	return b
+}
+
+func (b *fakeBroker) address() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+func (b *fakeBroker) waitAccept(t *testing.T) time.Time {
+	t.Helper()
+	select {
+	case at := <-b.accepted:
+		return at
+	case <-time.After(5 * time.Second):
+		t.Fatal("client did not connect")
+		return time.Time{}
+	}
+}
+
+func connack(conn net.Conn, code byte) {
+	conn.Write([]byte{packetConnack << 4, 2, 0, code})
+}
+
+// serve acknowledges pings until the client goes away.
+func serve(conn net.Conn, r *bufio.Reader) {
+	for {
+		p, err := readPacket(r)
+		if err != nil || p.kind == packetDisconnect {
+			return
+		}
+		if p.kind == packetPingreq {
+			conn.Write([]byte{packetPingresp << 4, 0})
+		}
+	}
+}
+
+func runClient(t *testing.T, opts Options) *Client {
+	t.Helper()
+	client := NewClient(opts)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		client.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return client
+}
+
+func TestClientBacksOffExponentially(t *testing.T) {
+	broker := newFakeBroker(t, func(n int, conn net.Conn, r *bufio.Reader) {
+		connack(conn, 5) // not authorized
+	})
+	runClient(t, Options{
+		Broker:     broker.address(),
+		ClientID:   "test",
+		MinBackoff: 20 * time.Millisecond,
+		MaxBackoff: 80 * time.Millisecond,
+	})
+
+	last := broker.waitAccept(t)
+	for _, want := range []time.Duration{20, 40, 80, 80} {
+		want *= time.Millisecond
+		at := broker.waitAccept(t)
+		if gap := at.Sub(last); gap < want {
+			t.Errorf("retried after %v, want at least %v", gap, want)
+		}
+		last = at
+	}
+}
+
+func TestClientResetsBackoffAfterSession(t *testing.T) {
+	const failures = 4
+	connected := make(chan *Client, 10)
+	broker := newFakeBroker(t, func(n int, conn net.Conn, r *bufio.Reader) {
+		if n < failures {
+			connack(conn, 3) // server unavailable
+			return
+		}
+		connack(conn, 0)
+		if n == failures {
+			// Drop the first good session once the client is up.
+			readPacket(r)
+			return
+		}
+		serve(conn, r)
+	})
+	client := runClient(t, Options{
+		Broker:     broker.address(),
+		ClientID:   "test",
+		MinBackoff: 20 * time.Millisecond,
+		MaxBackoff: time.Second,
+		OnConnect: func(c *Client) {
+			connected <- c
+			c.Subscribe("terrarium/+/set")
+		},
+	})
+
+	for range failures + 1 {
+		broker.waitAccept(t)
+	}
+	if c := <-connected; c != client {
+		t.Fatal("OnConnect got a different client")
+	}
+	dropped := time.Now()
+
+	// Without a reset the next delay would be 320ms.
+	if gap := broker.waitAccept(t).Sub(dropped); gap > 250*time.Millisecond {
+		t.Errorf("reconnected after %v, want about the minimum backoff", gap)
+	}
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnConnect was not called again after reconnecting")
+	}
+}
+
+func TestClientPublishAndReceive(t *testing.T) {
+	published := make(chan packet, 1)
+	acked := make(chan packet, 1)
+	broker := newFakeBroker(t, func(n int, conn net.Conn, r *bufio.Reader) {
+		connack(conn, 0)
+		p, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		published <- p
+
+		body := appendString(nil, "terrarium/pump/set")
+		body = append(body, 0, 9)
+		body = append(body, "ON"...)
+		data, _ := encodePacket(packetPublish, 0x02, body)
+		conn.Write(data)
+		if p, err := readPacket(r); err == nil {
+			acked <- p
+		}
+		serve(conn, r)
+	})
+
+	received := make(chan Message, 1)
+	ready := make(chan struct{})
+	client := runClient(t, Options{
+		Broker:    broker.address(),
+		ClientID:  "test",
+		OnConnect: func(*Client) { close(ready) },
+		OnMessage: func(msg Message) { received <- msg },
+	})
+
+	if err := client.Publish("terrarium/state", []byte("{}"), true); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("Publish before connecting returned %v, want ErrNotConnected", err)
+	}
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("client did not connect")
+	}
+	if err := client.Publish("terrarium/state", []byte("{}"), true); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, _, err := parsePublish(<-published)
+	if err != nil || msg.Topic != "terrarium/state" || !msg.Retain {
+		t.Fatalf("broker got %+v, %v", msg, err)
+	}
+	select {
+	case msg := <-received:
+		if msg.Topic != "terrarium/pump/set" || string(msg.Payload) != "ON" {
+			t.Fatalf("received %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not delivered")
+	}
+	if ack := <-acked; ack.kind != packetPuback || string(ack.body) != "\x00\x09" {
+		t.Fatalf("expected PUBACK for id 9, got type %d body %x", ack.kind, ack.body)
+	}
+}
+
+func TestClientReconnectsWhenBrokerGoesSilent(t *testing.T) {
+	broker := newFakeBroker(t, func(n int, conn net.Conn, r *bufio.Reader) {
+		connack(conn, 0)
+		// Never answer pings; the client must give up on the link.
+		io.Copy(io.Discard, r)
+	})
+	runClient(t, Options{
+		Broker:     broker.address(),
+		ClientID:   "test",
+		KeepAlive:  100 * time.Millisecond,
+		MinBackoff: 10 * time.Millisecond,
+	})
+
+	first := broker.waitAccept(t)
+	if gap := broker.waitAccept(t).Sub(first); gap < 150*time.Millisecond {
+		t.Errorf("reconnected after %v, before the keepalive deadline", gap)
+	}
+}
@@ -0,0 +1,172 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Control packet types (MQTT 3.1.1 section 2.2.1).
+const (
+	packetConnect    = 1
+	packetConnack    = 2
+	packetPublish    = 3
+	packetPuback     = 4
+	packetSubscribe  = 8
+	packetSuback     = 9
+	packetPingreq    = 12
+	packetPingresp   = 13
+	packetDisconnect = 14
+)
+
+const maxRemainingLength = 268435455
+
+var connackErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+type packet struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func encodePacket(kind, flags byte, body []byte) ([]byte, error) {
+	if len(body) > maxRemainingLength {
+		return nil, fmt.Errorf("packet of %d bytes exceeds the MQTT limit", len(body))
+	}
+	out := []byte{kind<<4 | flags}
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		out = append(out, digit)
+		if length == 0 {
+			break
+		}
+	}
+	return append(out, body...), nil
+}
+
+func readPacket(r *bufio.Reader) (packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return packet{}, errors.New("malformed remaining length")
+		}
+		digit, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		length += int(digit&0x7F) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return packet{}, err
+	}
+	return packet{kind: header >> 4, flags: header & 0x0F, body: body}, nil
+}
+
+func connectPacket(opts Options) ([]byte, error) {
+	body := appendString(nil, "MQTT")
+	body = append(body, 4) // protocol level 3.1.1
+
+	flags := byte(0x02) // clean session
+	if opts.Will != nil {
+		flags |= 0x04
+		if opts.Will.Retain {
+			flags |= 0x20
+		}
+	}
+	if opts.Username != "" {
+		flags |= 0x80
+		if opts.Password != "" {
+			flags |= 0x40
+		}
+	}
+	body = append(body, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(opts.KeepAlive.Seconds()))
+
+	body = appendString(body, opts.ClientID)
+	if opts.Will != nil {
+		body = appendString(body, opts.Will.Topic)
+		body = binary.BigEndian.AppendUint16(body, uint16(len(opts.Will.Payload)))
+		body = append(body, opts.Will.Payload...)
+	}
+	if opts.Username != "" {
+		body = appendString(body, opts.Username)
+		if opts.Password != "" {
+			body = appendString(body, opts.Password)
+		}
+	}
+	return encodePacket(packetConnect, 0, body)
+}
+
+func publishPacket(msg Message) ([]byte, error) {
+	var flags byte
+	if msg.Retain {
+		flags |= 0x01
+	}
+	body := appendString(nil, msg.Topic)
+	body = append(body, msg.Payload...)
+	return encodePacket(packetPublish, flags, body)
+}
+
+func subscribePacket(id uint16, filters []string) ([]byte, error) {
+	body := binary.BigEndian.AppendUint16(nil, id)
+	for _, filter := range filters {
+		body = appendString(body, filter)
+		body = append(body, 0) // QoS 0
+	}
+	return encodePacket(packetSubscribe, 0x02, body)
+}
+
+// parsePublish decodes an incoming PUBLISH, returning the packet id for
+// QoS 1 and 2 messages.
+func parsePublish(p packet) (Message, uint16, error) {
+	if len(p.body) < 2 {
+		return Message{}, 0, errors.New("short publish packet")
+	}
+	topicLen := int(binary.BigEndian.Uint16(p.body))
+	rest := p.body[2:]
+	if len(rest) < topicLen {
+		return Message{}, 0, errors.New("truncated publish topic")
+	}
+	msg := Message{Topic: string(rest[:topicLen]), Retain: p.flags&0x01 != 0}
+	rest = rest[topicLen:]
+
+	var id uint16
+	if qos := (p.flags >> 1) & 0x03; qos > 0 {
+		if len(rest) < 2 {
+			return Message{}, 0, errors.New("publish missing packet id")
+		}
+		id = binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+	}
+	msg.Payload = rest
+	return msg, id, nil
+}
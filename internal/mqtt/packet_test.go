@@ -0,0 +1,147 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestRemainingLengthRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		length int
+		header int // bytes of remaining length
+	}{
+		{0, 1}, {127, 1}, {128, 2}, {16383, 2}, {16384, 3}, {2097151, 3}, {2097152, 4},
+	} {
+		body := bytes.Repeat([]byte{0xAB}, tc.length)
+		encoded, err := encodePacket(packetPublish, 0x01, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(encoded) - tc.length - 1; got != tc.header {
+			t.Errorf("length %d encoded in %d bytes, want %d", tc.length, got, tc.header)
+		}
+
+		p, err := readPacket(bufio.NewReader(bytes.NewReader(encoded)))
+		if err != nil {
+			t.Fatalf("length %d: %v", tc.length, err)
+		}
+		if p.kind != packetPublish || p.flags != 0x01 || !bytes.Equal(p.body, body) {
+			t.Errorf("length %d decoded as kind %d flags %#x body %d bytes",
+				tc.length, p.kind, p.flags, len(p.body))
+		}
+	}
+}
+
+func TestReadPacketRejectsMalformedLength(t *testing.T) {
+	data := []byte{packetPublish << 4, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}
+	if _, err := readPacket(bufio.NewReader(bytes.NewReader(data))); err == nil {
+		t.Fatal("a five byte remaining length should be rejected")
+	}
+}
+
+func TestConnectPacket(t *testing.T) {
+	encoded, err := connectPacket(Options{
+		ClientID:  "terrarium",
+		Username:  "user",
+		Password:  "secret",
+		KeepAlive: 60 * time.Second,
+		Will:      &Message{Topic: "t/availability", Payload: []byte("offline"), Retain: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := readPacket(bufio.NewReader(bytes.NewReader(encoded)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.kind != packetConnect {
+		t.Fatalf("packet type %d, want CONNECT", p.kind)
+	}
+
+	var want []byte
+	want = appendString(want, "MQTT")
+	want = append(want, 4, 0x80|0x40|0x20|0x04|0x02)
+	want = binary.BigEndian.AppendUint16(want, 60)
+	want = appendString(want, "terrarium")
+	want = appendString(want, "t/availability")
+	want = appendString(want, "offline")
+	want = appendString(want, "user")
+	want = appendString(want, "secret")
+	if !bytes.Equal(p.body, want) {
+		t.Fatalf("CONNECT body\n got %x\nwant %x", p.body, want)
+	}
+}
+
+func TestConnectPacketMinimal(t *testing.T) {
+	encoded, err := connectPacket(Options{ClientID: "c", KeepAlive: 30 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := readPacket(bufio.NewReader(bytes.NewReader(encoded)))
+	if flags := p.body[7]; flags != 0x02 {
+		t.Fatalf("connect flags %#x, want clean session only", flags)
+	}
+}
+
+func TestPublishRoundTrip(t *testing.T) {
+	encoded, err := publishPacket(Message{Topic: "terrarium/state", Payload: []byte(`{"a":1}`), Retain: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := readPacket(bufio.NewReader(bytes.NewReader(encoded)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, id, err := parsePublish(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Topic != "terrarium/state" || string(msg.Payload) != `{"a":1}` || !msg.Retain || id != 0 {
+		t.Fatalf("decoded %+v id %d", msg, id)
+	}
+}
+
+func TestParsePublishQoS1(t *testing.T) {
+	body := appendString(nil, "terrarium/pump/set")
+	body = binary.BigEndian.AppendUint16(body, 42)
+	body = append(body, "ON"...)
+	msg, id, err := parsePublish(packet{kind: packetPublish, flags: 0x02, body: body})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Topic != "terrarium/pump/set" || string(msg.Payload) != "ON" || id != 42 {
+		t.Fatalf("decoded %+v id %d", msg, id)
+	}
+}
+
+func TestParsePublishRejectsTruncated(t *testing.T) {
+	for name, p := range map[string]packet{
+		"empty":     {kind: packetPublish},
+		"topic":     {kind: packetPublish, body: []byte{0, 10, 'a'}},
+		"packet id": {kind: packetPublish, flags: 0x02, body: appendString(nil, "a")},
+	} {
+		if _, _, err := parsePublish(p); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSubscribePacket(t *testing.T) {
+	encoded, err := subscribePacket(7, []string{"a/set", "b/set"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := readPacket(bufio.NewReader(bytes.NewReader(encoded)))
+	if p.kind != packetSubscribe || p.flags != 0x02 {
+		t.Fatalf("kind %d flags %#x, want SUBSCRIBE with flags 0x02", p.kind, p.flags)
+	}
+	want := binary.BigEndian.AppendUint16(nil, 7)
+	want = append(appendString(want, "a/set"), 0)
+	want = append(appendString(want, "b/set"), 0)
+	if !bytes.Equal(p.body, want) {
+		t.Fatalf("SUBSCRIBE body\n got %x\nwant %x", p.body, want)
+	}
+}
@@ -0,0 +1,258 @@
+// Package mqttbridge publishes the terrarium over MQTT, announces it to
+// Home Assistant and accepts relay and target commands.
+package mqttbridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/undeadpelmen/new-client/internal/mqtt"
+	"github.com/undeadpelmen/new-client/internal/pubsub"
+	"github.com/undeadpelmen/new-client/internal/terrarium"
+)
+
+const (
+	payloadOnline  = "online"
+	payloadOffline = "offline"
+	payloadOn      = "ON"
+	payloadOff     = "OFF"
+	// payloadAuto returns a relay to automatic control.
+	payloadAuto = "AUTO"
+)
+
+// Target ranges accepted from MQTT, also announced to Home Assistant.
+const (
+	minTargetTemperature = 10
+	maxTargetTemperature = 40
+	minTargetHumidity    = 20
+	maxTargetHumidity    = 100
+)
+
+var nodeIDInvalid = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// ValidateSettings rejects settings the bridge cannot run with.
+func ValidateSettings(cfg terrarium.MQTTSettings) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Broker == "" {
+		return fmt.Errorf("broker is required")
+	}
+	if cfg.ClientID == "" {
+		return fmt.Errorf("client_id is required")
+	}
+	for _, prefix := range []string{cfg.TopicPrefix, cfg.DiscoveryPrefix} {
+		if strings.ContainsAny(prefix, "+#") {
+			return fmt.Errorf("topic prefix %q must not contain wildcards", prefix)
+		}
+	}
+	if cfg.TopicPrefix == "" {
+		return fmt.Errorf("topic_prefix is required")
+	}
+	return nil
+}
+
+type Bridge struct {
+	cfg        terrarium.MQTTSettings
+	terrarium  *terrarium.Terrarium
+	controller *terrarium.TerrariumController
+	client     *mqtt.Client
+	nodeID     string
+}
+
+func New(cfg terrarium.MQTTSettings, t *terrarium.Terrarium, controller *terrarium.TerrariumController) *Bridge {
+	b := &Bridge{
+		cfg:        cfg,
+		terrarium:  t,
+		controller: controller,
+		nodeID:     nodeIDInvalid.ReplaceAllString(cfg.ClientID, "_"),
+	}
+	b.client = mqtt.NewClient(mqtt.Options{
+		Broker:    cfg.Broker,
+		ClientID:  cfg.ClientID,
+		Username:  cfg.Username,
+		Password:  cfg.Password,
+		KeepAlive: time.Duration(cfg.KeepAliveSeconds) * time.Second,
+		Will:      &mqtt.Message{Topic: b.topic("availability"), Payload: []byte(payloadOffline), Retain: true},
+		OnConnect: b.onConnect,
+		OnMessage: b.onMessage,
+	})
+	return b
+}
+
+func (b *Bridge) topic(parts ...string) string {
+	return b.cfg.TopicPrefix + "/" + strings.Join(parts, "/")
+}
+
+// Run forwards controller events until ctx is cancelled, then marks the
+// terrarium offline and disconnects.
+func (b *Bridge) Run(ctx context.Context) {
+	clientCtx, stopClient := context.WithCancel(context.Background())
+	clientDone := make(chan struct{})
+	go func() {
+		b.client.Run(clientCtx)
+		close(clientDone)
+	}()
+
+	sub := b.controller.Events().Subscribe(64)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// A clean disconnect suppresses the will, so say goodbye first.
+			b.publish(b.topic("availability"), []byte(payloadOffline), true)
+			stopClient()
+			<-clientDone
+			return
+		case msg := <-sub.C:
+			b.forward(msg)
+		}
+	}
+}
+
+func (b *Bridge) forward(msg pubsub.Message) {
+	switch msg.Type {
+	case pubsub.TypeState, pubsub.TypeRelay:
+		b.publishState()
+	case pubsub.TypeHistory:
+		if b.cfg.PublishHistory {
+			b.publishJSON(b.topic("history"), msg.Data, false)
+		}
+	case pubsub.TypeAlert:
+		b.publishJSON(b.topic("alert"), msg.Data, false)
+	}
+}
+
+func (b *Bridge) publish(topic string, payload []byte, retain bool) {
+	if err := b.client.Publish(topic, payload, retain); err != nil && err != mqtt.ErrNotConnected {
+		log.Printf("MQTT publish to %s failed: %v", topic, err)
+	}
+}
+
+func (b *Bridge) publishJSON(topic string, data any, retain bool) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("MQTT payload for %s: %v", topic, err)
+		return
+	}
+	b.publish(topic, payload, retain)
+}
+
+func onOff(on bool) string {
+	if on {
+		return payloadOn
+	}
+	return payloadOff
+}
+
+func (b *Bridge) publishState() {
+	state := b.terrarium.GetState()
+	settings := b.terrarium.GetSettings()
+
+	payload := map[string]any{
+		"temperature":                state.CurrentTemp,
+		"humidity":                   state.CurrentHumidity,
+		"light":                      onOff(state.LightRelay),
+		"heater":                     onOff(state.HeaterRelay),
+		"pump":                       onOff(state.PumpRelay),
+		"target_temperature":         state.TargetTemp,
+		"target_humidity":            state.TargetHumidity,
+		"target_temperature_setting": settings.Targets.Temperature,
+		"target_humidity_setting":    settings.Targets.Humidity,
+		"system_mode":                state.SystemMode,
+		"sensor_error":               onOff(state.SensorError),
+		"last_read":                  state.LastSensorRead.Format(time.RFC3339),
+	}
+	if state.CurrentPressure > 0 {
+		payload["pressure"] = state.CurrentPressure
+	}
+	if len(state.Channels) > 0 {
+		payload["channels"] = state.Channels
+	}
+	b.publishJSON(b.topic("state"), payload, true)
+}
+
+func (b *Bridge) onConnect(c *mqtt.Client) {
+	relays := []string{terrarium.RelayLight, terrarium.RelayHeater, terrarium.RelayPump}
+	filters := make([]string, 0, len(relays)+2)
+	for _, relay := range relays {
+		filters = append(filters, b.topic(relay, "set"))
+	}
+	filters = append(filters, b.topic("target_temperature", "set"), b.topic("target_humidity", "set"))
+	if err := c.Subscribe(filters...); err != nil {
+		log.Printf("MQTT subscribe failed: %v", err)
+	}
+
+	if b.cfg.DiscoveryPrefix != "" {
+		b.announce()
+	}
+	b.publish(b.topic("availability"), []byte(payloadOnline), true)
+	b.publishState()
+}
+
+func (b *Bridge) onMessage(msg mqtt.Message) {
+	payload := strings.TrimSpace(string(msg.Payload))
+
+	for _, relay := range []string{terrarium.RelayLight, terrarium.RelayHeater, terrarium.RelayPump} {
+		if msg.Topic != b.topic(relay, "set") {
+			continue
+		}
+		switch strings.ToUpper(payload) {
+		case payloadOn, payloadOff:
+			state := strings.ToUpper(payload) == payloadOn
+			if err := b.controller.SetOverride(relay, state, b.overrideDuration(relay, state)); err != nil {
+				log.Printf("MQTT command %s=%s failed: %v", relay, payload, err)
+			}
+		case payloadAuto:
+			b.controller.ClearOverride(relay)
+		default:
+			log.Printf("MQTT command for %s: unknown payload %q", relay, payload)
+		}
+		b.publishState()
+		return
+	}
+
+	switch msg.Topic {
+	case b.topic("target_temperature", "set"):
+		value, err := strconv.ParseFloat(payload, 32)
+		if err != nil || value < minTargetTemperature || value > maxTargetTemperature {
+			log.Printf("MQTT target temperature %q rejected", payload)
+			return
+		}
+		b.terrarium.UpdateSettings(func(s *terrarium.TerrariumSettings) {
+			s.Targets.Temperature = float32(value)
+		})
+	case b.topic("target_humidity", "set"):
+		value, err := strconv.ParseFloat(payload, 32)
+		if err != nil || value < minTargetHumidity || value > maxTargetHumidity {
+			log.Printf("MQTT target humidity %q rejected", payload)
+			return
+		}
+		b.terrarium.UpdateSettings(func(s *terrarium.TerrariumSettings) {
+			s.Targets.Humidity = float32(value)
+		})
+	default:
+		return
+	}
+	b.publishState()
+}
+
+// overrideDuration bounds pump commands: a retained or repeated ON runs one
+// misting pulse, or at most terrarium.MaxPumpOverride in continuous mode.
+// Other relays hold until AUTO.
+func (b *Bridge) overrideDuration(relay string, state bool) time.Duration {
+	if relay != terrarium.RelayPump || !state {
+		return 0
+	}
+	if seconds := b.terrarium.GetSettings().PumpSettings.DurationSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return terrarium.MaxPumpOverride
+}
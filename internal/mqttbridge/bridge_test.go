@@ -0,0 +1,128 @@
+package mqttbridge
+
+import (
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/undeadpelmen/new-client/internal/gpio"
+	"github.com/undeadpelmen/new-client/internal/mqtt"
+	"github.com/undeadpelmen/new-client/internal/terrarium"
+)
+
+var start = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newTestBridge returns a bridge whose client never connects; publishes
+// fail with ErrNotConnected and are dropped.
+func newTestBridge(t *testing.T) *Bridge {
+	t.Helper()
+	trm := terrarium.NewTerrarium()
+	trm.UpdateSettings(func(s *terrarium.TerrariumSettings) {
+		s.UseMockData = true
+		s.Targets.Temperature = 26
+		s.Targets.Humidity = 70
+		s.PumpSettings.DurationSeconds = 20
+	})
+	controller := terrarium.NewTerrariumController(trm, gpio.NewSimulatedRelays())
+	controller.SetClock(terrarium.NewManualClock(start))
+	return New(terrarium.MQTTSettings{
+		Enabled:     true,
+		Broker:      "tcp://127.0.0.1:1",
+		ClientID:    "test",
+		TopicPrefix: "terrarium",
+	}, trm, controller)
+}
+
+func TestOnMessageTargets(t *testing.T) {
+	tests := []struct {
+		name         string
+		topic        string
+		payload      string
+		wantTemp     float32
+		wantHumidity float32
+	}{
+		{"temperature", "terrarium/target_temperature/set", "27.5", 27.5, 70},
+		{"temperature at minimum", "terrarium/target_temperature/set", "10", 10, 70},
+		{"temperature below range", "terrarium/target_temperature/set", "5", 26, 70},
+		{"temperature above range", "terrarium/target_temperature/set", "45", 26, 70},
+		{"temperature not a number", "terrarium/target_temperature/set", "warm", 26, 70},
+		{"humidity", "terrarium/target_humidity/set", " 60 ", 26, 60},
+		{"humidity below range", "terrarium/target_humidity/set", "15", 26, 70},
+		{"humidity above range", "terrarium/target_humidity/set", "101", 26, 70},
+		{"unknown topic", "terrarium/target_pressure/set", "1000", 26, 70},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBridge(t)
+			b.onMessage(mqtt.Message{Topic: tt.topic, Payload: []byte(tt.payload)})
+
+			targets := b.terrarium.GetSettings().Targets
+			if targets.Temperature != tt.wantTemp || targets.Humidity != tt.wantHumidity {
+				t.Errorf("targets %.1f°C %.1f%%, want %.1f°C %.1f%%",
+					targets.Temperature, targets.Humidity, tt.wantTemp, tt.wantHumidity)
+			}
+		})
+	}
+}
+
+func TestOnMessageRelays(t *testing.T) {
+	tests := []struct {
+		name string
+		// pulseSeconds replaces the pump pulse duration when set.
+		pulseSeconds int
+		messages     []string // "relay=payload"
+		relay        string
+		wantOverride bool
+		wantState    bool
+		wantExpires  time.Duration // 0 holds until AUTO
+	}{
+		{"light on", 0, []string{"light=ON"}, terrarium.RelayLight, true, true, 0},
+		{"heater off, lower case", 0, []string{"heater=off"}, terrarium.RelayHeater, true, false, 0},
+		{"light back to auto", 0, []string{"light=ON", "light=AUTO"}, terrarium.RelayLight, false, false, 0},
+		{"pump on runs one pulse", 0, []string{"pump=ON"}, terrarium.RelayPump, true, true, 20 * time.Second},
+		{"pump on without pulses is bounded", -1, []string{"pump=ON"}, terrarium.RelayPump, true, true, terrarium.MaxPumpOverride},
+		{"pump off holds", 0, []string{"pump=OFF"}, terrarium.RelayPump, true, false, 0},
+		{"unknown payload", 0, []string{"pump=TOGGLE"}, terrarium.RelayPump, false, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBridge(t)
+			if tt.pulseSeconds != 0 {
+				b.terrarium.UpdateSettings(func(s *terrarium.TerrariumSettings) {
+					s.PumpSettings.DurationSeconds = max(tt.pulseSeconds, 0)
+				})
+			}
+			for _, message := range tt.messages {
+				relay, payload, _ := strings.Cut(message, "=")
+				b.onMessage(mqtt.Message{Topic: "terrarium/" + relay + "/set", Payload: []byte(payload)})
+			}
+
+			override, ok := b.terrarium.GetState().Overrides[tt.relay]
+			if ok != tt.wantOverride {
+				t.Fatalf("override present %v, want %v", ok, tt.wantOverride)
+			}
+			if !ok {
+				return
+			}
+			if override.State != tt.wantState {
+				t.Errorf("override state %v, want %v", override.State, tt.wantState)
+			}
+			switch {
+			case tt.wantExpires == 0 && override.Expires != nil:
+				t.Errorf("override expires at %v, want it to hold", *override.Expires)
+			case tt.wantExpires != 0 && override.Expires == nil:
+				t.Errorf("override holds, want it to expire after %v", tt.wantExpires)
+			case tt.wantExpires != 0 && !override.Expires.Equal(start.Add(tt.wantExpires)):
+				t.Errorf("override expires at %v, want %v", *override.Expires, start.Add(tt.wantExpires))
+			}
+		})
+	}
+}
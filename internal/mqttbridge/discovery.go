@@ -0,0 +1,132 @@
+package mqttbridge
+
+import (
+	"github.com/undeadpelmen/new-client/internal/terrarium"
+)
+
+// entity is one Home Assistant discovery config; fields are merged into
+// the common availability and device attributes.
+type entity struct {
+	component string
+	key       string
+	config    map[string]any
+}
+
+func (b *Bridge) entities() []entity {
+	state := b.topic("state")
+	entities := []entity{
+		{"sensor", "temperature", map[string]any{
+			"name":                "Temperature",
+			"device_class":        "temperature",
+			"state_class":         "measurement",
+			"unit_of_measurement": "°C",
+			"state_topic":         state,
+			"value_template":      "{{ value_json.temperature }}",
+		}},
+		{"sensor", "humidity", map[string]any{
+			"name":                "Humidity",
+			"device_class":        "humidity",
+			"state_class":         "measurement",
+			"unit_of_measurement": "%",
+			"state_topic":         state,
+			"value_template":      "{{ value_json.humidity }}",
+		}},
+		{"sensor", "target_temperature_effective", map[string]any{
+			"name":                "Effective target temperature",
+			"device_class":        "temperature",
+			"unit_of_measurement": "°C",
+			"state_topic":         state,
+			"value_template":      "{{ value_json.target_temperature }}",
+		}},
+		{"sensor", "target_humidity_effective", map[string]any{
+			"name":                "Effective target humidity",
+			"device_class":        "humidity",
+			"unit_of_measurement": "%",
+			"state_topic":         state,
+			"value_template":      "{{ value_json.target_humidity }}",
+		}},
+		{"sensor", "system_mode", map[string]any{
+			"name":           "System mode",
+			"state_topic":    state,
+			"value_template": "{{ value_json.system_mode }}",
+		}},
+		{"binary_sensor", "sensor_error", map[string]any{
+			"name":           "Sensor error",
+			"device_class":   "problem",
+			"state_topic":    state,
+			"value_template": "{{ value_json.sensor_error }}",
+			"payload_on":     payloadOn,
+			"payload_off":    payloadOff,
+		}},
+		{"number", "target_temperature", map[string]any{
+			"name":                "Target temperature",
+			"unit_of_measurement": "°C",
+			"min":                 minTargetTemperature,
+			"max":                 maxTargetTemperature,
+			"step":                0.5,
+			"mode":                "box",
+			"state_topic":         state,
+			"value_template":      "{{ value_json.target_temperature_setting }}",
+			"command_topic":       b.topic("target_temperature", "set"),
+		}},
+		{"number", "target_humidity", map[string]any{
+			"name":                "Target humidity",
+			"unit_of_measurement": "%",
+			"min":                 minTargetHumidity,
+			"max":                 maxTargetHumidity,
+			"step":                1,
+			"mode":                "box",
+			"state_topic":         state,
+			"value_template":      "{{ value_json.target_humidity_setting }}",
+			"command_topic":       b.topic("target_humidity", "set"),
+		}},
+	}
+
+	if b.terrarium.GetState().CurrentPressure > 0 {
+		entities = append(entities, entity{"sensor", "pressure", map[string]any{
+			"name":                "Pressure",
+			"device_class":        "atmospheric_pressure",
+			"state_class":         "measurement",
+			"unit_of_measurement": "hPa",
+			"state_topic":         state,
+			"value_template":      "{{ value_json.pressure }}",
+		}})
+	}
+
+	for _, relay := range []struct{ key, name, icon string }{
+		{terrarium.RelayLight, "Light", "mdi:lightbulb"},
+		{terrarium.RelayHeater, "Heater", "mdi:radiator"},
+		{terrarium.RelayPump, "Pump", "mdi:water-pump"},
+	} {
+		entities = append(entities, entity{"switch", relay.key, map[string]any{
+			"name":           relay.name,
+			"icon":           relay.icon,
+			"state_topic":    state,
+			"value_template": "{{ value_json." + relay.key + " }}",
+			"command_topic":  b.topic(relay.key, "set"),
+			"payload_on":     payloadOn,
+			"payload_off":    payloadOff,
+		}})
+	}
+	return entities
+}
+
+// announce publishes retained discovery configs so Home Assistant creates
+// the entities on its own.
+func (b *Bridge) announce() {
+	device := map[string]any{
+		"identifiers":  []string{b.nodeID},
+		"name":         "Terrarium " + b.nodeID,
+		"manufacturer": "new-client",
+		"model":        "Terrarium controller",
+		"sw_version":   "2.0.0",
+	}
+	for _, e := range b.entities() {
+		config := e.config
+		config["unique_id"] = b.nodeID + "_" + e.key
+		config["object_id"] = b.nodeID + "_" + e.key
+		config["availability_topic"] = b.topic("availability")
+		config["device"] = device
+		b.publishJSON(b.cfg.DiscoveryPrefix+"/"+e.component+"/"+b.nodeID+"/"+e.key+"/config", config, true)
+	}
+}
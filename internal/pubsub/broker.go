@@ -0,0 +1,93 @@
+// Package pubsub fans controller events out to any number of subscribers.
+package pubsub
+
+import (
+	"sync"
+	"time"
+)
+
+// Message types published by the controller.
+const (
+	TypeState   = "state"
+	TypeRelay   = "relay"
+	TypeHistory = "history"
+	TypeAlert   = "alert"
+)
+
+type Message struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+// Subscription receives messages on C until Close is called. A subscriber
+// that falls behind by more than its buffer loses messages rather than
+// stalling the publisher; Dropped counts them.
+type Subscription struct {
+	C       <-chan Message
+	ch      chan Message
+	broker  *Broker
+	mu      sync.Mutex
+	dropped int
+}
+
+func (s *Subscription) Dropped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+func (s *Subscription) Close() {
+	s.broker.unsubscribe(s)
+}
+
+type Broker struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[*Subscription]struct{})}
+}
+
+func (b *Broker) Subscribe(buffer int) *Subscription {
+	ch := make(chan Message, buffer)
+	sub := &Subscription{C: ch, ch: ch, broker: b}
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+func (b *Broker) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Publish delivers the message to every subscriber without blocking.
+func (b *Broker) Publish(msgType string, at time.Time, data any) {
+	msg := Message{Type: msgType, Time: at, Data: data}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs {
+		select {
+		case sub.ch <- msg:
+		default:
+			sub.mu.Lock()
+			sub.dropped++
+			sub.mu.Unlock()
+		}
+	}
+}
+
+func (b *Broker) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
@@ -0,0 +1,221 @@
+package sensor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"periph.io/x/conn/v3/i2c"
+)
+
+const (
+	BME280DefaultAddress = 0x76
+
+	bme280RegChipID   = 0xD0
+	bme280RegReset    = 0xE0
+	bme280RegCtrlHum  = 0xF2
+	bme280RegStatus   = 0xF3
+	bme280RegCtrlMeas = 0xF4
+	bme280RegData     = 0xF7
+	bme280RegCalib00  = 0x88
+	bme280RegCalib26  = 0xE1
+	bme280ChipID      = 0x60
+)
+
+func init() {
+	Register("bme280", func(cfg Config) (ClimateSensor, error) {
+		if cfg.Bus == nil {
+			return nil, fmt.Errorf("bme280 driver requires an I2C bus")
+		}
+		address := cfg.Address
+		if address == 0 {
+			address = BME280DefaultAddress
+		}
+		return NewBME280(cfg.Bus, address)
+	})
+}
+
+type bme280Calibration struct {
+	t1             uint16
+	t2, t3         int16
+	p1             uint16
+	p2, p3, p4, p5 int16
+	p6, p7, p8, p9 int16
+	h1             uint8
+	h2             int16
+	h3             uint8
+	h4, h5         int16
+	h6             int8
+}
+
+// BME280 reads temperature, humidity and pressure in forced mode, one
+// measurement per call.
+type BME280 struct {
+	mu    sync.Mutex
+	dev   *i2c.Dev
+	calib bme280Calibration
+}
+
+func NewBME280(bus i2c.Bus, address uint16) (*BME280, error) {
+	b := &BME280{dev: &i2c.Dev{Bus: bus, Addr: address}}
+
+	id, err := b.readReg(bme280RegChipID, 1)
+	if err != nil {
+		return nil, fmt.Errorf("bme280 at 0x%02X not responding: %v", address, err)
+	}
+	if id[0] != bme280ChipID {
+		return nil, fmt.Errorf("unexpected chip id 0x%02X at 0x%02X", id[0], address)
+	}
+
+	if err := b.dev.Tx([]byte{bme280RegReset, 0xB6}, nil); err != nil {
+		return nil, fmt.Errorf("bme280 reset failed: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	if err := b.readCalibration(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *BME280) readReg(reg byte, n int) ([]byte, error) {
+	data := make([]byte, n)
+	if err := b.dev.Tx([]byte{reg}, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (b *BME280) readCalibration() error {
+	c0, err := b.readReg(bme280RegCalib00, 26)
+	if err != nil {
+		return fmt.Errorf("failed to read calibration block 0: %v", err)
+	}
+	c1, err := b.readReg(bme280RegCalib26, 7)
+	if err != nil {
+		return fmt.Errorf("failed to read calibration block 1: %v", err)
+	}
+
+	le := binary.LittleEndian
+	b.calib = bme280Calibration{
+		t1: le.Uint16(c0[0:]),
+		t2: int16(le.Uint16(c0[2:])),
+		t3: int16(le.Uint16(c0[4:])),
+		p1: le.Uint16(c0[6:]),
+		p2: int16(le.Uint16(c0[8:])),
+		p3: int16(le.Uint16(c0[10:])),
+		p4: int16(le.Uint16(c0[12:])),
+		p5: int16(le.Uint16(c0[14:])),
+		p6: int16(le.Uint16(c0[16:])),
+		p7: int16(le.Uint16(c0[18:])),
+		p8: int16(le.Uint16(c0[20:])),
+		p9: int16(le.Uint16(c0[22:])),
+		h1: c0[25],
+		h2: int16(le.Uint16(c1[0:])),
+		h3: c1[2],
+		h4: int16(int8(c1[3]))<<4 | int16(c1[4]&0x0F),
+		h5: int16(int8(c1[5]))<<4 | int16(c1[4]>>4),
+		h6: int8(c1[6]),
+	}
+	return nil
+}
+
+func (b *BME280) Name() string {
+	return "bme280"
+}
+
+func (b *BME280) ReadClimate() (*Reading, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Oversampling x1 for all channels; ctrl_hum only takes effect after
+	// the following ctrl_meas write.
+	if err := b.dev.Tx([]byte{bme280RegCtrlHum, 0x01}, nil); err != nil {
+		return nil, fmt.Errorf("bme280 ctrl_hum write failed: %v", err)
+	}
+	if err := b.dev.Tx([]byte{bme280RegCtrlMeas, 0x25}, nil); err != nil {
+		return nil, fmt.Errorf("bme280 forced mode write failed: %v", err)
+	}
+
+	deadline := time.Now().Add(100 * time.Millisecond)
+	for {
+		time.Sleep(10 * time.Millisecond)
+		status, err := b.readReg(bme280RegStatus, 1)
+		if err != nil {
+			return nil, fmt.Errorf("bme280 status read failed: %v", err)
+		}
+		if status[0]&0x08 == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("bme280 measurement %w", ErrTimeout)
+		}
+	}
+
+	raw, err := b.readReg(bme280RegData, 8)
+	if err != nil {
+		return nil, fmt.Errorf("bme280 data read failed: %v", err)
+	}
+	adcP := int32(raw[0])<<12 | int32(raw[1])<<4 | int32(raw[2])>>4
+	adcT := int32(raw[3])<<12 | int32(raw[4])<<4 | int32(raw[5])>>4
+	adcH := int32(raw[6])<<8 | int32(raw[7])
+	if adcT == 0x80000 || adcH == 0x8000 {
+		return nil, fmt.Errorf("bme280 returned skipped measurement")
+	}
+
+	temp, tFine := b.compensateTemperature(adcT)
+	return &Reading{
+		Temperature: float32(temp),
+		Humidity:    float32(b.compensateHumidity(adcH, tFine)),
+		Pressure:    float32(b.compensatePressure(adcP, tFine) / 100.0),
+		Timestamp:   time.Now(),
+		Quality:     QualityGood,
+	}, nil
+}
+
+// The compensation formulas follow the floating point reference
+// implementation in the BME280 datasheet, section 8.1.
+
+func (b *BME280) compensateTemperature(adcT int32) (float64, float64) {
+	c := b.calib
+	x := float64(adcT)
+	var1 := (x/16384.0 - float64(c.t1)/1024.0) * float64(c.t2)
+	d := x/131072.0 - float64(c.t1)/8192.0
+	var2 := d * d * float64(c.t3)
+	tFine := var1 + var2
+	return tFine / 5120.0, tFine
+}
+
+// compensatePressure returns pressure in Pa.
+func (b *BME280) compensatePressure(adcP int32, tFine float64) float64 {
+	c := b.calib
+	var1 := tFine/2.0 - 64000.0
+	var2 := var1 * var1 * float64(c.p6) / 32768.0
+	var2 = var2 + var1*float64(c.p5)*2.0
+	var2 = var2/4.0 + float64(c.p4)*65536.0
+	var1 = (float64(c.p3)*var1*var1/524288.0 + float64(c.p2)*var1) / 524288.0
+	var1 = (1.0 + var1/32768.0) * float64(c.p1)
+	if var1 == 0 {
+		return 0
+	}
+	p := 1048576.0 - float64(adcP)
+	p = (p - var2/4096.0) * 6250.0 / var1
+	var1 = float64(c.p9) * p * p / 2147483648.0
+	var2 = p * float64(c.p8) / 32768.0
+	return p + (var1+var2+float64(c.p7))/16.0
+}
+
+func (b *BME280) compensateHumidity(adcH int32, tFine float64) float64 {
+	c := b.calib
+	h := tFine - 76800.0
+	h = (float64(adcH) - (float64(c.h4)*64.0 + float64(c.h5)/16384.0*h)) *
+		(float64(c.h2) / 65536.0 * (1.0 + float64(c.h6)/67108864.0*h*(1.0+float64(c.h3)/67108864.0*h)))
+	h = h * (1.0 - float64(c.h1)*h/524288.0)
+	if h > 100.0 {
+		return 100.0
+	} else if h < 0.0 {
+		return 0.0
+	}
+	return h
+}
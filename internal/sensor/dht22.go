@@ -57,10 +57,10 @@ func (d *DHT22) sendStartSignal() error {
 
 func (d *DHT22) readBit() (byte, error) {
 	if !waitForPinState(d.pin, gpio.Low, 100) {
-		return 0, fmt.Errorf("timeout waiting for bit start")
+		return 0, fmt.Errorf("%w waiting for bit start", ErrTimeout)
 	}
 	if !waitForPinState(d.pin, gpio.High, 100) {
-		return 0, fmt.Errorf("timeout waiting for high")
+		return 0, fmt.Errorf("%w waiting for high", ErrTimeout)
 	}
 	start := time.Now()
 	for d.pin.Read() == gpio.High {
@@ -78,15 +78,15 @@ func (d *DHT22) readBit() (byte, error) {
 func (d *DHT22) read40Bits() ([]byte, error) {
 	data := make([]byte, 5)
 	if !waitForPinState(d.pin, gpio.Low, 100) {
-		return nil, fmt.Errorf("sensor response timeout (low)")
+		return nil, fmt.Errorf("sensor response %w (low)", ErrTimeout)
 	}
 	if !waitForPinState(d.pin, gpio.High, 100) {
-		return nil, fmt.Errorf("sensor response timeout (high)")
+		return nil, fmt.Errorf("sensor response %w (high)", ErrTimeout)
 	}
 	for i := 0; i < 40; i++ {
 		bit, err := d.readBit()
 		if err != nil {
-			return nil, fmt.Errorf("error reading bit %d: %v", i, err)
+			return nil, fmt.Errorf("error reading bit %d: %w", i, err)
 		}
 		byteIndex := i / 8
 		bitPosition := 7 - (i % 8)
@@ -117,10 +117,10 @@ func (d *DHT22) parseData(data []byte) (*DHT22Reading, error) {
 		temperature = -temperature
 	}
 	if humidity < 0 || humidity > 100 {
-		return nil, fmt.Errorf("humidity out of range: %.1f", humidity)
+		return nil, fmt.Errorf("humidity %w: %.1f", ErrRange, humidity)
 	}
 	if temperature < -40 || temperature > 80 {
-		return nil, fmt.Errorf("temperature out of range: %.1f", temperature)
+		return nil, fmt.Errorf("temperature %w: %.1f", ErrRange, temperature)
 	}
 	return &DHT22Reading{
 		Temperature: temperature,
@@ -135,10 +135,10 @@ func (d *DHT22) Read() (*DHT22Reading, error) {
 	}
 	data, err := d.read40Bits()
 	if err != nil {
-		return nil, fmt.Errorf("read bits failed: %v", err)
+		return nil, fmt.Errorf("read bits failed: %w", err)
 	}
 	if !d.verifyChecksum(data) {
-		return nil, fmt.Errorf("checksum mismatch")
+		return nil, ErrChecksum
 	}
 	return d.parseData(data)
 }
@@ -155,5 +155,52 @@ func (d *DHT22) ReadWithRetry(maxAttempts int) (*DHT22Reading, error) {
 			time.Sleep(2 * time.Second)
 		}
 	}
-	return nil, fmt.Errorf("failed after %d attempts: %v", maxAttempts, lastErr)
+	return nil, fmt.Errorf("failed after %d attempts: %w", maxAttempts, lastErr)
+}
+
+func init() {
+	Register("dht22", func(cfg Config) (ClimateSensor, error) {
+		if cfg.Pin == nil {
+			return nil, fmt.Errorf("dht22 driver requires a GPIO pin")
+		}
+		retries := cfg.Retries
+		if retries <= 0 {
+			retries = 2
+		}
+		return &DHT22Sensor{dev: NewDHT22(cfg.Pin, cfg.Pin.Name()), retries: retries}, nil
+	})
+}
+
+// DHT22Sensor adapts the bit-banged DHT22 to the ClimateSensor interface.
+type DHT22Sensor struct {
+	dev     *DHT22
+	retries int
+}
+
+func (s *DHT22Sensor) Name() string {
+	return "dht22"
+}
+
+func (s *DHT22Sensor) ReadClimate() (*Reading, error) {
+	var lastErr error
+	for attempt := 1; attempt <= s.retries; attempt++ {
+		reading, err := s.dev.Read()
+		if err == nil && reading.Valid {
+			quality := QualityGood
+			if attempt > 1 {
+				quality = QualityDegraded
+			}
+			return &Reading{
+				Temperature: reading.Temperature,
+				Humidity:    reading.Humidity,
+				Timestamp:   time.Now(),
+				Quality:     quality,
+			}, nil
+		}
+		lastErr = err
+		if attempt < s.retries {
+			time.Sleep(2 * time.Second)
+		}
+	}
+	return nil, fmt.Errorf("failed after %d attempts: %w", s.retries, lastErr)
 }
@@ -0,0 +1,104 @@
+package sensor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultOneWireRoot = "/sys/bus/w1/devices"
+
+	ds18b20FamilyPrefix = "28-"
+	// 85 °C is the DS18B20 power-on value, reported when a conversion
+	// did not complete.
+	ds18b20ResetValue = 85000
+)
+
+// OneWireBus reads DS18B20 probes through the kernel w1 sysfs interface.
+// The root is configurable so a fake directory tree can stand in for sysfs.
+type OneWireBus struct {
+	root string
+}
+
+func NewOneWireBus(root string) *OneWireBus {
+	if root == "" {
+		root = DefaultOneWireRoot
+	}
+	return &OneWireBus{root: root}
+}
+
+// Probes returns the IDs of all DS18B20 devices found under every
+// w1_bus_master directory.
+func (b *OneWireBus) Probes() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(b.root, "w1_bus_master*", ds18b20FamilyPrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var ids []string
+	for _, match := range matches {
+		id := filepath.Base(match)
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (b *OneWireBus) probePath(id string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(b.root, "w1_bus_master*", id, "w1_slave"))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("probe %s not found", id)
+	}
+	return matches[0], nil
+}
+
+// ReadTemperature parses the two-line w1_slave file, e.g.
+//
+//	72 01 4b 46 7f ff 0e 10 57 : crc=57 YES
+//	72 01 4b 46 7f ff 0e 10 57 t=23125
+func (b *OneWireBus) ReadTemperature(id string) (float32, error) {
+	path, err := b.probePath(id)
+	if err != nil {
+		return 0, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("probe %s read failed: %v", id, err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) < 2 {
+		return 0, fmt.Errorf("probe %s returned truncated data", id)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(lines[0]), "YES") {
+		return 0, fmt.Errorf("probe %s %w", id, ErrChecksum)
+	}
+	idx := strings.LastIndex(lines[1], "t=")
+	if idx < 0 {
+		return 0, fmt.Errorf("probe %s returned no temperature", id)
+	}
+	milli, err := strconv.Atoi(strings.TrimSpace(lines[1][idx+2:]))
+	if err != nil {
+		return 0, fmt.Errorf("probe %s returned invalid temperature: %v", id, err)
+	}
+	if milli == ds18b20ResetValue {
+		return 0, fmt.Errorf("probe %s returned power-on reset value", id)
+	}
+
+	temp := float32(milli) / 1000
+	if temp < -55 || temp > 125 {
+		return 0, fmt.Errorf("probe %s temperature %w: %.1f", id, ErrRange, temp)
+	}
+	return temp, nil
+}
@@ -0,0 +1,111 @@
+package sensor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const (
+	w1CRCGood = "72 01 4b 46 7f ff 0e 10 57 : crc=57 YES\n"
+	w1CRCBad  = "72 01 4b 46 7f ff 0e 10 57 : crc=57 NO\n"
+)
+
+// writeProbe creates master/id/w1_slave under root like the kernel w1
+// driver does.
+func writeProbe(t *testing.T, root, master, id, contents string) {
+	t.Helper()
+	dir := filepath.Join(root, master, id)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "w1_slave"), []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOneWireProbes(t *testing.T) {
+	root := t.TempDir()
+	writeProbe(t, root, "w1_bus_master1", "28-000000000002", w1CRCGood+"72 01 t=23125\n")
+	writeProbe(t, root, "w1_bus_master1", "28-000000000001", w1CRCGood+"72 01 t=23125\n")
+	writeProbe(t, root, "w1_bus_master2", "28-000000000002", w1CRCGood+"72 01 t=23125\n")
+	// A DS18S20 and a file outside any bus master are not DS18B20 probes.
+	writeProbe(t, root, "w1_bus_master1", "10-000000000003", w1CRCGood+"72 01 t=23125\n")
+	writeProbe(t, root, "other", "28-000000000004", w1CRCGood+"72 01 t=23125\n")
+
+	ids, err := NewOneWireBus(root).Probes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"28-000000000001", "28-000000000002"}; !slices.Equal(ids, want) {
+		t.Fatalf("probes %v, want %v", ids, want)
+	}
+}
+
+func TestOneWireReadTemperature(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     float32
+		wantErr  error // matched with errors.Is when set
+		fails    bool
+	}{
+		{"valid", w1CRCGood + "72 01 4b 46 7f ff 0e 10 57 t=23125\n", 23.125, nil, false},
+		{"below zero", w1CRCGood + "5e ff 4b 46 7f ff 02 10 0c t=-10125\n", -10.125, nil, false},
+		{"crc mismatch", w1CRCBad + "72 01 4b 46 7f ff 0e 10 57 t=23125\n", 0, ErrChecksum, true},
+		{"power-on value", w1CRCGood + "50 05 4b 46 7f ff 0c 10 1c t=85000\n", 0, nil, true},
+		{"out of range", w1CRCGood + "72 01 4b 46 7f ff 0e 10 57 t=130000\n", 0, ErrRange, true},
+		{"truncated", w1CRCGood, 0, nil, true},
+		{"no temperature", w1CRCGood + "72 01 4b 46 7f ff 0e 10 57\n", 0, nil, true},
+		{"garbled temperature", w1CRCGood + "72 01 4b 46 7f ff 0e 10 57 t=2x\n", 0, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeProbe(t, root, "w1_bus_master1", "28-000000000001", tt.contents)
+
+			temp, err := NewOneWireBus(root).ReadTemperature("28-000000000001")
+			switch {
+			case !tt.fails && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.fails && err == nil:
+				t.Fatalf("read %.3f°C, want an error", temp)
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			case !tt.fails && temp != tt.want:
+				t.Fatalf("read %.3f°C, want %.3f°C", temp, tt.want)
+			}
+		})
+	}
+}
+
+func TestOneWireProbeDisappears(t *testing.T) {
+	root := t.TempDir()
+	writeProbe(t, root, "w1_bus_master1", "28-000000000001", w1CRCGood+"72 01 t=23125\n")
+	writeProbe(t, root, "w1_bus_master1", "28-000000000002", w1CRCGood+"72 01 t=24500\n")
+	bus := NewOneWireBus(root)
+
+	if _, err := bus.ReadTemperature("28-000000000001"); err != nil {
+		t.Fatal(err)
+	}
+	// The kernel removes the device directory when a probe drops off.
+	if err := os.RemoveAll(filepath.Join(root, "w1_bus_master1", "28-000000000001")); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := bus.Probes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"28-000000000002"}; !slices.Equal(ids, want) {
+		t.Fatalf("probes %v after removal, want %v", ids, want)
+	}
+	if _, err := bus.ReadTemperature("28-000000000001"); err == nil {
+		t.Fatal("reading a removed probe should fail")
+	}
+	if temp, err := bus.ReadTemperature("28-000000000002"); err != nil || temp != 24.5 {
+		t.Fatalf("remaining probe read %.3f, %v", temp, err)
+	}
+}
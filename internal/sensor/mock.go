@@ -0,0 +1,16 @@
+package sensor
+
+import "fmt"
+
+// DriverMock reads the simulated enclosure instead of hardware. Simulation
+// mode selects it regardless of the configured driver.
+const DriverMock = "mock"
+
+func init() {
+	Register(DriverMock, func(cfg Config) (ClimateSensor, error) {
+		if cfg.Simulated == nil {
+			return nil, fmt.Errorf("no simulated enclosure")
+		}
+		return cfg.Simulated, nil
+	})
+}
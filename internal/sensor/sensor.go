@@ -0,0 +1,112 @@
+package sensor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/i2c"
+)
+
+// Drivers wrap these errors so callers can tell failure modes apart.
+var (
+	ErrTimeout  = errors.New("timeout")
+	ErrChecksum = errors.New("checksum mismatch")
+	ErrRange    = errors.New("out of range")
+)
+
+// Failure types reported by FailureType.
+const (
+	FailureTimeout  = "timeout"
+	FailureChecksum = "checksum"
+	FailureRange    = "range"
+	FailureOther    = "other"
+)
+
+// FailureType classifies a read error by the sentinel it wraps.
+func FailureType(err error) string {
+	switch {
+	case errors.Is(err, ErrTimeout):
+		return FailureTimeout
+	case errors.Is(err, ErrChecksum):
+		return FailureChecksum
+	case errors.Is(err, ErrRange):
+		return FailureRange
+	}
+	return FailureOther
+}
+
+type Quality string
+
+const (
+	QualityGood      Quality = "good"
+	QualityDegraded  Quality = "degraded"
+	QualitySimulated Quality = "simulated"
+)
+
+// Reading is a single measurement. Pressure is in hPa and left at zero by
+// drivers that cannot measure it.
+type Reading struct {
+	Temperature float32   `json:"temperature"`
+	Humidity    float32   `json:"humidity"`
+	Pressure    float32   `json:"pressure,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+	Quality     Quality   `json:"quality"`
+}
+
+// ClimateSensor is implemented by every air temperature/humidity driver.
+type ClimateSensor interface {
+	Name() string
+	ReadClimate() (*Reading, error)
+}
+
+// Config carries the hardware handles a driver may need. Drivers ignore
+// fields they do not use.
+type Config struct {
+	Pin     gpio.PinIO
+	Bus     i2c.Bus
+	Address uint16
+	Retries int
+	// Simulated is the enclosure model read by the mock driver.
+	Simulated ClimateSensor
+}
+
+type Factory func(cfg Config) (ClimateSensor, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("sensor: driver %q registered twice", name))
+	}
+	registry[name] = factory
+}
+
+func Open(name string, cfg Config) (ClimateSensor, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown sensor driver %q (available: %v)", name, Drivers())
+	}
+	return factory(cfg)
+}
+
+func Drivers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
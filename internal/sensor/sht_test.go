@@ -0,0 +1,106 @@
+package sensor
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"periph.io/x/conn/v3/i2c"
+	"periph.io/x/conn/v3/physic"
+)
+
+// fakeSHTBus answers single-shot measurements with 25 °C and 50 %RH and
+// signals heaterOn when the SHT3x heater enable command arrives.
+type fakeSHTBus struct {
+	mu       sync.Mutex
+	writes   [][]byte
+	heaterOn chan struct{}
+}
+
+func (b *fakeSHTBus) String() string                    { return "fake" }
+func (b *fakeSHTBus) SetSpeed(f physic.Frequency) error { return nil }
+
+func (b *fakeSHTBus) Tx(addr uint16, w, r []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(w) > 0 {
+		b.writes = append(b.writes, append([]byte(nil), w...))
+		if bytes.Equal(w, []byte{0x30, 0x6D}) {
+			close(b.heaterOn)
+		}
+	}
+	if len(r) == 6 {
+		copy(r, []byte{0x66, 0x66, 0, 0x80, 0x00, 0})
+		r[2] = crc8(r[0:2])
+		r[5] = crc8(r[3:5])
+	}
+	return nil
+}
+
+func (b *fakeSHTBus) sent(cmd []byte) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := 0
+	for _, w := range b.writes {
+		if bytes.Equal(w, cmd) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSHTReadsDuringHeaterPulse(t *testing.T) {
+	bus := &fakeSHTBus{heaterOn: make(chan struct{})}
+	s := &SHT{dev: &i2c.Dev{Bus: bus, Addr: SHTDefaultAddress}, variant: sht3xVariant}
+
+	reading, err := s.ReadClimate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reading.Temperature != 25 || reading.Humidity < 49.9 || reading.Humidity > 50.1 || reading.Quality != QualityGood {
+		t.Fatalf("reading %+v, want 25 °C, 50 %%RH and good quality", reading)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.HeaterPulse(500 * time.Millisecond) }()
+	<-bus.heaterOn
+
+	// The pulse must not hold the sensor for its whole length.
+	start := time.Now()
+	during, err := s.ReadClimate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("read during pulse took %v", elapsed)
+	}
+	if during.Temperature != 25 || during.Quality != QualityDegraded {
+		t.Errorf("reading during pulse %+v, want the last reading marked degraded", during)
+	}
+	if n := bus.sent(sht3xVariant.measureCmd); n != 1 {
+		t.Errorf("%d measurements sent, want none while the heater runs", n-1)
+	}
+	if err := s.HeaterPulse(time.Second); err == nil {
+		t.Error("second pulse started while the first was running")
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if bus.sent([]byte{0x30, 0x66}) != 1 {
+		t.Error("heater not disabled after the pulse")
+	}
+	after, err := s.ReadClimate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Quality != QualityDegraded {
+		t.Errorf("quality %s right after a pulse, want degraded until the sensor settles", after.Quality)
+	}
+	if n := bus.sent(sht3xVariant.measureCmd); n != 2 {
+		t.Errorf("%d measurements sent, want a fresh one after the pulse", n)
+	}
+}
@@ -0,0 +1,188 @@
+package simulation
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/undeadpelmen/new-client/internal/gpio"
+	"github.com/undeadpelmen/new-client/internal/sensor"
+)
+
+// Params describes the simulated enclosure. Temperatures are in °C,
+// humidity in %RH and all times in seconds.
+type Params struct {
+	AmbientTemperature float64 `json:"ambient_temperature"`
+	AmbientHumidity    float64 `json:"ambient_humidity"`
+	// HeaterGain and LightGain are the steady-state rise above ambient
+	// with the relay permanently on.
+	HeaterGain float64 `json:"heater_gain"`
+	LightGain  float64 `json:"light_gain"`
+	// ThermalTimeConstant is how quickly air approaches its equilibrium;
+	// HeaterLag is how quickly the heater element itself warms and cools.
+	ThermalTimeConstant float64 `json:"thermal_time_constant"`
+	HeaterLag           float64 `json:"heater_lag"`
+	// PumpRate is the humidity added per second of misting, which then
+	// decays toward ambient with HumidityDecay.
+	PumpRate         float64 `json:"pump_rate"`
+	HumidityDecay    float64 `json:"humidity_decay"`
+	TemperatureNoise float64 `json:"temperature_noise"`
+	HumidityNoise    float64 `json:"humidity_noise"`
+}
+
+func DefaultParams() Params {
+	return Params{
+		AmbientTemperature:  22.0,
+		AmbientHumidity:     45.0,
+		HeaterGain:          10.0,
+		LightGain:           2.0,
+		ThermalTimeConstant: 900,
+		HeaterLag:           120,
+		PumpRate:            1.0,
+		HumidityDecay:       1200,
+		TemperatureNoise:    0.1,
+		HumidityNoise:       0.5,
+	}
+}
+
+// Validate rejects parameters no enclosure could have: humidity outside
+// 0-100 % and negative gains, rates, time constants or noise.
+func (p Params) Validate() error {
+	if p.AmbientTemperature < -40 || p.AmbientTemperature > 60 {
+		return fmt.Errorf("ambient_temperature %.1f out of range", p.AmbientTemperature)
+	}
+	if p.AmbientHumidity < 0 || p.AmbientHumidity > 100 {
+		return fmt.Errorf("ambient_humidity %.1f out of range", p.AmbientHumidity)
+	}
+	for _, field := range []struct {
+		name  string
+		value float64
+	}{
+		{"heater_gain", p.HeaterGain},
+		{"light_gain", p.LightGain},
+		{"thermal_time_constant", p.ThermalTimeConstant},
+		{"heater_lag", p.HeaterLag},
+		{"pump_rate", p.PumpRate},
+		{"humidity_decay", p.HumidityDecay},
+		{"temperature_noise", p.TemperatureNoise},
+		{"humidity_noise", p.HumidityNoise},
+	} {
+		if field.value < 0 {
+			return fmt.Errorf("%s must not be negative", field.name)
+		}
+	}
+	return nil
+}
+
+// maxStep bounds the integration step so relay changes between reads are
+// not smeared across long gaps.
+const maxStep = 10 * time.Second
+
+// Environment integrates a first-order thermal and humidity model from the
+// relay states it observes and reports the result as a ClimateSensor.
+type Environment struct {
+	mu       sync.Mutex
+	params   Params
+	relays   func() gpio.RelayStates
+	now      func() time.Time
+	rng      *rand.Rand
+	lastStep time.Time
+	temp     float64
+	humidity float64
+	element  float64
+}
+
+func NewEnvironment(params Params, relays func() gpio.RelayStates) *Environment {
+	return &Environment{
+		params:   params,
+		relays:   relays,
+		now:      time.Now,
+		rng:      rand.New(rand.NewPCG(uint64(time.Now().UnixNano()), 0)),
+		temp:     params.AmbientTemperature,
+		humidity: params.AmbientHumidity,
+	}
+}
+
+func (e *Environment) SetParams(params Params) {
+	e.mu.Lock()
+	e.params = params
+	e.mu.Unlock()
+}
+
+func (e *Environment) SetClock(now func() time.Time) {
+	e.mu.Lock()
+	e.now = now
+	e.lastStep = time.Time{}
+	e.mu.Unlock()
+}
+
+func (e *Environment) Name() string {
+	return "simulation"
+}
+
+func (e *Environment) ReadClimate() (*sensor.Reading, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	e.advance(now)
+
+	humidity := e.humidity + e.rng.NormFloat64()*e.params.HumidityNoise
+	humidity = math.Max(0, math.Min(100, humidity))
+	return &sensor.Reading{
+		Temperature: float32(e.temp + e.rng.NormFloat64()*e.params.TemperatureNoise),
+		Humidity:    float32(humidity),
+		Timestamp:   now,
+		Quality:     sensor.QualitySimulated,
+	}, nil
+}
+
+func (e *Environment) advance(now time.Time) {
+	if e.lastStep.IsZero() {
+		e.lastStep = now
+		return
+	}
+
+	states := e.relays()
+	for e.lastStep.Before(now) {
+		step := now.Sub(e.lastStep)
+		if step > maxStep {
+			step = maxStep
+		}
+		e.step(step.Seconds(), states)
+		e.lastStep = e.lastStep.Add(step)
+	}
+}
+
+// step applies the exact solution of each first-order equation over dt,
+// which stays stable for any step size.
+func (e *Environment) step(dt float64, states gpio.RelayStates) {
+	p := e.params
+
+	heaterTarget := 0.0
+	if states.Heater {
+		heaterTarget = 1.0
+	}
+	e.element = approach(e.element, heaterTarget, dt, p.HeaterLag)
+
+	tempTarget := p.AmbientTemperature + p.HeaterGain*e.element
+	if states.Light {
+		tempTarget += p.LightGain
+	}
+	e.temp = approach(e.temp, tempTarget, dt, p.ThermalTimeConstant)
+
+	humidityTarget := p.AmbientHumidity
+	if states.Pump {
+		humidityTarget += p.PumpRate * p.HumidityDecay
+	}
+	e.humidity = math.Min(100, approach(e.humidity, humidityTarget, dt, p.HumidityDecay))
+}
+
+func approach(value, target, dt, timeConstant float64) float64 {
+	if timeConstant <= 0 {
+		return target
+	}
+	return target + (value-target)*math.Exp(-dt/timeConstant)
+}
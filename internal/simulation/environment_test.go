@@ -0,0 +1,171 @@
+package simulation_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/undeadpelmen/new-client/internal/gpio"
+	"github.com/undeadpelmen/new-client/internal/simulation"
+	"github.com/undeadpelmen/new-client/internal/terrarium"
+)
+
+var start = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// testEnvironment is an enclosure without noise on a manual clock, driven
+// by relays the test switches directly.
+type testEnvironment struct {
+	env    *simulation.Environment
+	clock  *terrarium.ManualClock
+	relays gpio.RelayStates
+}
+
+func newTestEnvironment(t *testing.T, params simulation.Params) *testEnvironment {
+	t.Helper()
+	params.TemperatureNoise = 0
+	params.HumidityNoise = 0
+	te := &testEnvironment{clock: terrarium.NewManualClock(start)}
+	te.env = simulation.NewEnvironment(params, func() gpio.RelayStates { return te.relays })
+	te.env.SetClock(te.clock.Now)
+	te.read(t) // the first read only starts the model clock
+	return te
+}
+
+// run advances the clock by d and reads the enclosure.
+func (te *testEnvironment) run(t *testing.T, d time.Duration) (float64, float64) {
+	t.Helper()
+	te.clock.Advance(d)
+	return te.read(t)
+}
+
+func (te *testEnvironment) read(t *testing.T) (float64, float64) {
+	t.Helper()
+	reading, err := te.env.ReadClimate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return float64(reading.Temperature), float64(reading.Humidity)
+}
+
+func expectNear(t *testing.T, what string, got, want, tolerance float64) {
+	t.Helper()
+	if math.Abs(got-want) > tolerance {
+		t.Errorf("%s = %.3f, want %.3f ± %.3f", what, got, want, tolerance)
+	}
+}
+
+func TestEnvironmentHeaterLag(t *testing.T) {
+	params := simulation.DefaultParams()
+	te := newTestEnvironment(t, params)
+	te.relays.Heater = true
+
+	// The element has to warm up before the air follows.
+	temp, _ := te.run(t, 30*time.Second)
+	if rise := temp - params.AmbientTemperature; rise > 0.05 {
+		t.Errorf("air rose %.3f°C within 30 s of switching on", rise)
+	}
+
+	noLag := params
+	noLag.HeaterLag = 0
+	instant := newTestEnvironment(t, noLag)
+	instant.relays.Heater = true
+	lagged, _ := te.run(t, 870*time.Second)
+	direct, _ := instant.run(t, 900*time.Second)
+	if lagged >= direct {
+		t.Errorf("with heater lag %.2f°C after 15 min, without %.2f°C; lag should slow the rise", lagged, direct)
+	}
+	// Without lag the air follows one time constant: 1 - 1/e of the gain.
+	expectNear(t, "air after one time constant", direct,
+		params.AmbientTemperature+params.HeaterGain*(1-math.Exp(-1)), 0.05)
+
+	temp, _ = te.run(t, 4*time.Hour)
+	expectNear(t, "steady heated air", temp, params.AmbientTemperature+params.HeaterGain, 0.05)
+
+	te.relays.Heater = false
+	temp, _ = te.run(t, 4*time.Hour)
+	expectNear(t, "air after cooling", temp, params.AmbientTemperature, 0.05)
+}
+
+func TestEnvironmentLightGain(t *testing.T) {
+	params := simulation.DefaultParams()
+	te := newTestEnvironment(t, params)
+	te.relays.Light = true
+
+	temp, _ := te.run(t, 4*time.Hour)
+	expectNear(t, "air under the light", temp, params.AmbientTemperature+params.LightGain, 0.05)
+}
+
+func TestEnvironmentPumpHumidity(t *testing.T) {
+	params := simulation.DefaultParams()
+	params.AmbientHumidity = 40
+	te := newTestEnvironment(t, params)
+
+	// Misting adds about PumpRate per second while far from saturation.
+	te.relays.Pump = true
+	_, humidity := te.run(t, 20*time.Second)
+	added := humidity - params.AmbientHumidity
+	expectNear(t, "humidity added in 20 s", added, 20*params.PumpRate, 0.5)
+
+	// Afterwards the excess decays with HumidityDecay.
+	te.relays.Pump = false
+	_, humidity = te.run(t, time.Duration(params.HumidityDecay)*time.Second)
+	expectNear(t, "humidity after one decay constant", humidity,
+		params.AmbientHumidity+added*math.Exp(-1), 0.1)
+
+	// Long misting saturates at 100 %.
+	te.relays.Pump = true
+	_, humidity = te.run(t, 4*time.Hour)
+	if humidity != 100 {
+		t.Errorf("humidity %.2f%% after hours of misting, want 100%%", humidity)
+	}
+}
+
+func TestEnvironmentAmbientDrift(t *testing.T) {
+	params := simulation.DefaultParams()
+	te := newTestEnvironment(t, params)
+
+	warmer := params
+	warmer.AmbientTemperature = 30
+	warmer.AmbientHumidity = 60
+	warmer.TemperatureNoise, warmer.HumidityNoise = 0, 0
+	te.env.SetParams(warmer)
+
+	temp, humidity := te.run(t, time.Duration(params.ThermalTimeConstant)*time.Second)
+	expectNear(t, "air after one time constant", temp,
+		30-(30-params.AmbientTemperature)*math.Exp(-1), 0.01)
+	if humidity <= params.AmbientHumidity || humidity >= 60 {
+		t.Errorf("humidity %.2f%% should be drifting from %.0f%% toward 60%%", humidity, params.AmbientHumidity)
+	}
+
+	temp, humidity = te.run(t, 12*time.Hour)
+	expectNear(t, "air after drifting", temp, 30, 0.01)
+	expectNear(t, "humidity after drifting", humidity, 60, 0.01)
+}
+
+func TestParamsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(*simulation.Params)
+		wantErr bool
+	}{
+		{"defaults", func(*simulation.Params) {}, false},
+		{"instant response", func(p *simulation.Params) { p.ThermalTimeConstant, p.HeaterLag = 0, 0 }, false},
+		{"hot ambient", func(p *simulation.Params) { p.AmbientTemperature = 80 }, true},
+		{"humidity above 100", func(p *simulation.Params) { p.AmbientHumidity = 120 }, true},
+		{"negative humidity", func(p *simulation.Params) { p.AmbientHumidity = -1 }, true},
+		{"negative heater gain", func(p *simulation.Params) { p.HeaterGain = -5 }, true},
+		{"negative heater lag", func(p *simulation.Params) { p.HeaterLag = -1 }, true},
+		{"negative time constant", func(p *simulation.Params) { p.ThermalTimeConstant = -900 }, true},
+		{"negative humidity decay", func(p *simulation.Params) { p.HumidityDecay = -1 }, true},
+		{"negative noise", func(p *simulation.Params) { p.TemperatureNoise = -0.1 }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := simulation.DefaultParams()
+			tt.change(&params)
+			if err := params.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
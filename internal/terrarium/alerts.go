@@ -0,0 +1,103 @@
+package terrarium
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/undeadpelmen/new-client/internal/alert"
+	"github.com/undeadpelmen/new-client/internal/pubsub"
+)
+
+// relayFault describes relays whose driver state differs from the state
+// the controller commanded, or is empty when they agree. pumpMu is held so
+// a pulse timer cannot switch the pump between the two reads.
+func (tc *TerrariumController) relayFault() string {
+	tc.pumpMu.Lock()
+	defer tc.pumpMu.Unlock()
+
+	actual := tc.relays.States()
+	state := tc.terrarium.GetState()
+
+	var faults []string
+	if actual.Light != state.LightRelay {
+		faults = append(faults, fmt.Sprintf("light is %v, expected %v", actual.Light, state.LightRelay))
+	}
+	if actual.Heater != state.HeaterRelay {
+		faults = append(faults, fmt.Sprintf("heater is %v, expected %v", actual.Heater, state.HeaterRelay))
+	}
+	if actual.Pump != state.PumpRelay {
+		faults = append(faults, fmt.Sprintf("pump is %v, expected %v", actual.Pump, state.PumpRelay))
+	}
+	return strings.Join(faults, "; ")
+}
+
+// evaluateAlerts checks the alert rules against the state left by the
+// cycle that just ran and sends any resulting notifications.
+func (tc *TerrariumController) evaluateAlerts(settings *TerrariumSettings) {
+	fault := tc.relayFault()
+
+	var obs alert.Observation
+	tc.terrarium.UpdateState(func(s *TerrariumState) {
+		s.RelayFault = fault
+		obs = alert.Observation{
+			Time:        tc.clock.Now(),
+			Temperature: s.CurrentTemp,
+			Humidity:    s.CurrentHumidity,
+			SensorError: s.SensorError,
+			LastRead:    s.LastSensorRead,
+			RelayFault:  fault,
+			SystemMode:  s.SystemMode,
+		}
+	})
+
+	events := tc.alerts.Evaluate(settings.Alerts.Rules, obs)
+	for _, event := range events {
+		tc.events.Publish(pubsub.TypeAlert, event.Time, event)
+	}
+	if len(events) > 0 {
+		alert.Dispatch(settings.Alerts.Notifiers.Build(), events)
+	}
+}
+
+func (tc *TerrariumController) Alerts() []alert.Status {
+	return tc.alerts.Statuses()
+}
+
+func (tc *TerrariumController) AcknowledgeAlert(id string) error {
+	return tc.alerts.Acknowledge(id)
+}
+
+// SnoozeAlert silences notifications for the rule for the given duration;
+// zero cancels an active snooze.
+func (tc *TerrariumController) SnoozeAlert(id string, duration time.Duration) error {
+	var until time.Time
+	if duration > 0 {
+		until = tc.clock.Now().Add(duration)
+	}
+	return tc.alerts.Snooze(id, until)
+}
+
+// TestNotifiers sends a test event through every configured notifier and
+// returns the failures by notifier name.
+func (tc *TerrariumController) TestNotifiers(ctx context.Context) map[string]string {
+	event := alert.Event{
+		RuleID:   "test",
+		Type:     "test",
+		Severity: alert.SeverityInfo,
+		State:    alert.StateFiring,
+		Message:  "Test notification from the terrarium controller",
+		Time:     tc.clock.Now(),
+	}
+
+	results := make(map[string]string)
+	for _, notifier := range tc.terrarium.GetSettings().Alerts.Notifiers.Build() {
+		if err := notifier.Notify(ctx, event); err != nil {
+			results[notifier.Name()] = err.Error()
+		} else {
+			results[notifier.Name()] = "ok"
+		}
+	}
+	return results
+}
@@ -0,0 +1,101 @@
+package terrarium
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type Timer interface {
+	Stop() bool
+}
+
+// Clock is the time source of the control logic. The real clock is used in
+// production; ManualClock lets a simulation advance time explicitly.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+type ManualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*manualTimer
+}
+
+type manualTimer struct {
+	clock    *ManualClock
+	deadline time.Time
+	fn       func()
+	stopped  bool
+}
+
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := &manualTimer{clock: c, deadline: c.now.Add(d), fn: f}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+// Advance moves the clock forward by d, running due timers in deadline
+// order with the clock set to each deadline.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		sort.SliceStable(c.timers, func(i, j int) bool {
+			return c.timers[i].deadline.Before(c.timers[j].deadline)
+		})
+		if len(c.timers) == 0 || c.timers[0].deadline.After(end) {
+			break
+		}
+		timer := c.timers[0]
+		c.timers = c.timers[1:]
+		if timer.stopped {
+			continue
+		}
+		if timer.deadline.After(c.now) {
+			c.now = timer.deadline
+		}
+		c.mu.Unlock()
+		timer.fn()
+		c.mu.Lock()
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+func (t *manualTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	wasActive := !t.stopped
+	t.stopped = true
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return wasActive
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package terrarium
+
+import (
+	"fmt"
+	"time"
+)
+
+// Night target sources: light follows the scheduled light state, clock
+// uses NightTargets.Start and End.
+const (
+	NightSourceLight = "light"
+	NightSourceClock = "clock"
+)
+
+// NightTargets lowers or raises the climate targets at night. Temperature
+// and Humidity are absolute when the day targets come from Targets; with a
+// seasonal profile they keep the same difference from the seasonal day
+// targets. RampMinutes spreads each day/night transition over that time.
+type NightTargets struct {
+	Enabled     bool    `json:"enabled"`
+	Temperature float32 `json:"temperature"`
+	Humidity    float32 `json:"humidity"`
+	Source      string  `json:"source"`
+	Start       string  `json:"start,omitempty"`
+	End         string  `json:"end,omitempty"`
+	RampMinutes int     `json:"ramp_minutes"`
+}
+
+// ValidateNightTargets rejects unknown sources and malformed clock times.
+func ValidateNightTargets(night NightTargets) error {
+	if night.Humidity < 0 || night.Humidity > 100 {
+		return fmt.Errorf("humidity %.1f out of range", night.Humidity)
+	}
+	if night.RampMinutes < 0 {
+		return fmt.Errorf("negative ramp of %d minutes", night.RampMinutes)
+	}
+	switch night.Source {
+	case NightSourceLight:
+		return nil
+	case NightSourceClock:
+		if _, err := (LightWindow{Start: night.Start, End: night.End}).weekMinutes(); err != nil {
+			return fmt.Errorf("night window: %v", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown night source %q (expected %s or %s)", night.Source, NightSourceLight, NightSourceClock)
+}
+
+// isNight reports whether the night targets apply at now; lightScheduled
+// is the light state the schedule asks for, ignoring overrides.
+func (night NightTargets) isNight(lightScheduled bool, now time.Time) bool {
+	if !night.Enabled {
+		return false
+	}
+	if night.Source == NightSourceClock {
+		return lightWindowsActive([]LightWindow{{Start: night.Start, End: night.End}}, now)
+	}
+	return !lightScheduled
+}
+
+// dayNightRamp blends between day (0) and night (1) targets, moving
+// linearly towards the current phase over the ramp time.
+type dayNightRamp struct {
+	started bool
+	night   bool
+	from    float32
+	since   time.Time
+}
+
+// Update returns the night weight at now. The first call starts in the
+// current phase without ramping, so a restart does not replay a transition.
+func (r *dayNightRamp) Update(night bool, rampMinutes int, now time.Time) float32 {
+	target := float32(0)
+	if night {
+		target = 1
+	}
+	if !r.started {
+		r.started, r.night, r.from, r.since = true, night, target, now
+		return target
+	}
+	if night != r.night {
+		// Reverse from wherever the previous ramp got to.
+		r.from = r.weight(rampMinutes, now)
+		r.night = night
+		r.since = now
+	}
+	return r.weight(rampMinutes, now)
+}
+
+func (r *dayNightRamp) weight(rampMinutes int, now time.Time) float32 {
+	target := float32(0)
+	if r.night {
+		target = 1
+	}
+	ramp := time.Duration(rampMinutes) * time.Minute
+	elapsed := now.Sub(r.since)
+	if ramp <= 0 || elapsed >= ramp {
+		return target
+	}
+	return r.from + (target-r.from)*float32(elapsed)/float32(ramp)
+}
@@ -0,0 +1,77 @@
+package terrarium
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/undeadpelmen/new-client/internal/gpio"
+	"github.com/undeadpelmen/new-client/internal/pubsub"
+)
+
+// RelayEvent is published whenever a relay actually changes state.
+type RelayEvent struct {
+	Relay string `json:"relay"`
+	State bool   `json:"state"`
+}
+
+// publishingRelays wraps the controller's driver and publishes a relay
+// event for every switch that changes the reported state, whichever code
+// path caused it.
+type publishingRelays struct {
+	driver gpio.RelayDriver
+	tc     *TerrariumController
+}
+
+func (pr *publishingRelays) switchRelay(name string, state bool, set func(bool) error, get func(gpio.RelayStates) bool) error {
+	before := get(pr.driver.States())
+	err := set(state)
+	if after := get(pr.driver.States()); after != before {
+		pr.tc.counters.relaySwitch(name)
+		pr.tc.events.Publish(pubsub.TypeRelay, pr.tc.clock.Now(), RelayEvent{Relay: name, State: after})
+	}
+	return err
+}
+
+func (pr *publishingRelays) SetLight(state bool) error {
+	return pr.switchRelay(RelayLight, state, pr.driver.SetLight, func(s gpio.RelayStates) bool { return s.Light })
+}
+
+func (pr *publishingRelays) SetHeater(state bool) error {
+	return pr.switchRelay(RelayHeater, state, pr.driver.SetHeater, func(s gpio.RelayStates) bool { return s.Heater })
+}
+
+func (pr *publishingRelays) SetPump(state bool) error {
+	return pr.switchRelay(RelayPump, state, pr.driver.SetPump, func(s gpio.RelayStates) bool { return s.Pump })
+}
+
+func (pr *publishingRelays) States() gpio.RelayStates {
+	return pr.driver.States()
+}
+
+func (pr *publishingRelays) Shutdown() {
+	pr.driver.Shutdown()
+}
+
+// Events returns the broker carrying state, relay, history and alert
+// messages from the controller.
+func (tc *TerrariumController) Events() *pubsub.Broker {
+	return tc.events
+}
+
+// publishState sends a snapshot of the state, encoded while the state
+// lock is held so subscribers never see a half-updated cycle.
+func (tc *TerrariumController) publishState() {
+	if tc.events.Subscribers() == 0 {
+		return
+	}
+	var snapshot json.RawMessage
+	var err error
+	tc.terrarium.UpdateState(func(s *TerrariumState) {
+		snapshot, err = json.Marshal(s)
+	})
+	if err != nil {
+		log.Printf("Failed to encode state snapshot: %v", err)
+		return
+	}
+	tc.events.Publish(pubsub.TypeState, tc.clock.Now(), snapshot)
+}
@@ -0,0 +1,342 @@
+package terrarium
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type HistoryStore interface {
+	Append(record HistoricalRecord) error
+	Recent(limit int) ([]HistoricalRecord, error)
+	Count() int
+	Close() error
+}
+
+const memoryHistoryLimit = 1000
+
+type MemoryHistoryStore struct {
+	mu      sync.RWMutex
+	records []HistoricalRecord
+}
+
+func NewMemoryHistoryStore() *MemoryHistoryStore {
+	return &MemoryHistoryStore{records: make([]HistoricalRecord, 0)}
+}
+
+func (m *MemoryHistoryStore) Append(record HistoricalRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, record)
+	if len(m.records) > memoryHistoryLimit {
+		m.records = m.records[len(m.records)-memoryHistoryLimit:]
+	}
+	return nil
+}
+
+func (m *MemoryHistoryStore) Recent(limit int) ([]HistoricalRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return tailRecords(m.records, limit), nil
+}
+
+func (m *MemoryHistoryStore) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.records)
+}
+
+func (m *MemoryHistoryStore) Close() error {
+	return nil
+}
+
+func tailRecords(records []HistoricalRecord, limit int) []HistoricalRecord {
+	if limit <= 0 || limit > len(records) {
+		limit = len(records)
+	}
+	result := make([]HistoricalRecord, limit)
+	copy(result, records[len(records)-limit:])
+	return result
+}
+
+const (
+	segmentPrefix        = "history-"
+	segmentSuffix        = ".jsonl"
+	defaultSegmentSize   = 1 << 20
+	defaultMaxSegments   = 90
+	defaultFlushRecords  = 12
+	defaultFlushInterval = time.Minute
+	segmentTailCache     = memoryHistoryLimit
+)
+
+// SegmentHistoryStore appends records as JSON lines to size-bounded segment
+// files. Records are buffered and written in batches to keep SD card wear
+// low; at most one batch is lost on power failure, and a torn final line is
+// truncated when the store is reopened.
+type SegmentHistoryStore struct {
+	mu            sync.Mutex
+	dir           string
+	segmentSize   int64
+	maxSegments   int
+	flushRecords  int
+	flushInterval time.Duration
+
+	segments  []string
+	current   *os.File
+	currentSz int64
+	pending   []HistoricalRecord
+	lastFlush time.Time
+	tail      []HistoricalRecord
+	count     int
+}
+
+func NewSegmentHistoryStore(dir string) (*SegmentHistoryStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory %s: %v", dir, err)
+	}
+
+	hs := &SegmentHistoryStore{
+		dir:           dir,
+		segmentSize:   defaultSegmentSize,
+		maxSegments:   defaultMaxSegments,
+		flushRecords:  defaultFlushRecords,
+		flushInterval: defaultFlushInterval,
+		lastFlush:     time.Now(),
+	}
+
+	if err := hs.scan(); err != nil {
+		return nil, err
+	}
+	return hs, nil
+}
+
+func (hs *SegmentHistoryStore) scan() error {
+	entries, err := os.ReadDir(hs.dir)
+	if err != nil {
+		return fmt.Errorf("failed to list history directory: %v", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		hs.segments = append(hs.segments, name)
+	}
+	sort.Strings(hs.segments)
+
+	for i, name := range hs.segments {
+		last := i == len(hs.segments)-1
+		records, err := hs.readSegment(name, last)
+		if err != nil {
+			return err
+		}
+		hs.count += len(records)
+		hs.tail = append(hs.tail, records...)
+		if len(hs.tail) > segmentTailCache {
+			hs.tail = hs.tail[len(hs.tail)-segmentTailCache:]
+		}
+	}
+
+	log.Printf("History store %s: %d records in %d segments", hs.dir, hs.count, len(hs.segments))
+	return nil
+}
+
+// readSegment decodes every complete line of a segment. For the newest
+// segment a partially written trailing line is cut off so appends resume on
+// a clean boundary.
+func (hs *SegmentHistoryStore) readSegment(name string, repair bool) ([]HistoricalRecord, error) {
+	path := filepath.Join(hs.dir, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history segment %s: %v", name, err)
+	}
+
+	var records []HistoricalRecord
+	var good int64
+	reader := bufio.NewReader(bytes.NewReader(data))
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		var record HistoricalRecord
+		if jsonErr := json.Unmarshal(line, &record); jsonErr != nil {
+			log.Printf("Skipping corrupt history record in %s: %v", name, jsonErr)
+		} else {
+			records = append(records, record)
+		}
+		good += int64(len(line))
+	}
+
+	if repair && good < int64(len(data)) {
+		log.Printf("Truncating torn write at end of %s (%d bytes)", name, int64(len(data))-good)
+		if err := os.Truncate(path, good); err != nil {
+			return nil, fmt.Errorf("failed to repair history segment %s: %v", name, err)
+		}
+	}
+	return records, nil
+}
+
+func (hs *SegmentHistoryStore) Append(record HistoricalRecord) error {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	hs.pending = append(hs.pending, record)
+	hs.tail = append(hs.tail, record)
+	if len(hs.tail) > segmentTailCache {
+		hs.tail = hs.tail[len(hs.tail)-segmentTailCache:]
+	}
+	hs.count++
+
+	if len(hs.pending) >= hs.flushRecords || time.Since(hs.lastFlush) >= hs.flushInterval {
+		return hs.flushLocked()
+	}
+	return nil
+}
+
+func (hs *SegmentHistoryStore) flushLocked() error {
+	hs.lastFlush = time.Now()
+	if len(hs.pending) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, record := range hs.pending {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode history record: %v", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	if hs.current == nil || hs.currentSz+int64(buf.Len()) > hs.segmentSize {
+		if err := hs.rotateLocked(int64(buf.Len())); err != nil {
+			return err
+		}
+	}
+
+	n, err := hs.current.Write(buf.Bytes())
+	if err != nil {
+		// Cut a partial batch off again so the retry does not write its
+		// records twice behind a torn line.
+		if n > 0 {
+			if truncErr := hs.current.Truncate(hs.currentSz); truncErr != nil {
+				log.Printf("Failed to undo partial history write, dropping %d records: %v", len(hs.pending), truncErr)
+				hs.currentSz += int64(n)
+				hs.pending = hs.pending[:0]
+			}
+		}
+		return fmt.Errorf("failed to write history segment: %v", err)
+	}
+	hs.currentSz += int64(n)
+	if err := hs.current.Sync(); err != nil {
+		return fmt.Errorf("failed to sync history segment: %v", err)
+	}
+	hs.pending = hs.pending[:0]
+	return nil
+}
+
+// rotateLocked opens a segment with room for need more bytes.
+func (hs *SegmentHistoryStore) rotateLocked(need int64) error {
+	if hs.current != nil {
+		if err := hs.current.Close(); err != nil {
+			log.Printf("Failed to close history segment: %v", err)
+		}
+		hs.current = nil
+	}
+
+	// Reopen the newest segment after a restart if the batch still fits.
+	name := ""
+	if len(hs.segments) > 0 {
+		newest := hs.segments[len(hs.segments)-1]
+		if info, err := os.Stat(filepath.Join(hs.dir, newest)); err == nil && info.Size()+need <= hs.segmentSize {
+			name = newest
+		}
+	}
+	if name == "" {
+		name = fmt.Sprintf("%s%020d%s", segmentPrefix, time.Now().UnixNano(), segmentSuffix)
+		hs.segments = append(hs.segments, name)
+	}
+
+	f, err := os.OpenFile(filepath.Join(hs.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open history segment %s: %v", name, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat history segment %s: %v", name, err)
+	}
+	hs.current = f
+	hs.currentSz = info.Size()
+
+	for len(hs.segments) > hs.maxSegments {
+		oldest := hs.segments[0]
+		records, err := hs.readSegment(oldest, false)
+		if err == nil {
+			hs.count -= len(records)
+		}
+		if err := os.Remove(filepath.Join(hs.dir, oldest)); err != nil {
+			log.Printf("Failed to remove old history segment %s: %v", oldest, err)
+		}
+		hs.segments = hs.segments[1:]
+	}
+	return nil
+}
+
+func (hs *SegmentHistoryStore) Recent(limit int) ([]HistoricalRecord, error) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	if limit > 0 && limit <= len(hs.tail) {
+		return tailRecords(hs.tail, limit), nil
+	}
+	if limit <= 0 || limit > hs.count {
+		limit = hs.count
+	}
+	if limit <= len(hs.tail) {
+		return tailRecords(hs.tail, limit), nil
+	}
+
+	// Walk segments newest first until enough records are collected.
+	collected := append([]HistoricalRecord(nil), hs.pending...)
+	for i := len(hs.segments) - 1; i >= 0 && len(collected) < limit; i-- {
+		records, err := hs.readSegment(hs.segments[i], false)
+		if err != nil {
+			return nil, err
+		}
+		collected = append(records, collected...)
+	}
+	return tailRecords(collected, limit), nil
+}
+
+func (hs *SegmentHistoryStore) Count() int {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	return hs.count
+}
+
+func (hs *SegmentHistoryStore) Close() error {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	err := hs.flushLocked()
+	if hs.current != nil {
+		if closeErr := hs.current.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		hs.current = nil
+	}
+	return err
+}
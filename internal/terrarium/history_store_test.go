@@ -0,0 +1,193 @@
+package terrarium
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var historyStart = time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+// historyRecord i encodes to the same length for every i, so segment sizes
+// can be given in lines.
+func historyRecord(i int) HistoricalRecord {
+	return HistoricalRecord{Timestamp: historyStart.Add(time.Duration(i) * time.Second), Temperature: 25, Humidity: 70}
+}
+
+func historyLine(t *testing.T, i int) []byte {
+	t.Helper()
+	line, err := json.Marshal(historyRecord(i))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(line, '\n')
+}
+
+func openHistory(t *testing.T, dir string, segmentLines, maxSegments, flushRecords int) *SegmentHistoryStore {
+	t.Helper()
+	hs, err := NewSegmentHistoryStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs.segmentSize = int64(segmentLines * len(historyLine(t, 0)))
+	hs.maxSegments = maxSegments
+	hs.flushRecords = flushRecords
+	return hs
+}
+
+func appendHistory(t *testing.T, hs *SegmentHistoryStore, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		if err := hs.Append(historyRecord(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// expectRecords checks that records hold historyRecord(from) onwards
+// without gaps or repeats.
+func expectRecords(t *testing.T, records []HistoricalRecord, from, to int) {
+	t.Helper()
+	if len(records) != to-from {
+		t.Fatalf("got %d records, want %d", len(records), to-from)
+	}
+	for i, record := range records {
+		if want := historyRecord(from + i).Timestamp; !record.Timestamp.Equal(want) {
+			t.Fatalf("record %d has timestamp %v, want %v", i, record.Timestamp, want)
+		}
+	}
+}
+
+// segmentLines returns the number of lines in each segment file, oldest
+// first, failing if one is larger than size bytes.
+func segmentLines(t *testing.T, dir string, size int64) []int {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []int
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), segmentPrefix) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int64(len(data)) > size {
+			t.Errorf("segment %s is %d bytes, limit %d", entry.Name(), len(data), size)
+		}
+		lines = append(lines, bytes.Count(data, []byte{'\n'}))
+	}
+	return lines
+}
+
+func TestSegmentHistoryRepairsTornTail(t *testing.T) {
+	dir := t.TempDir()
+	good := append(historyLine(t, 0), historyLine(t, 1)...)
+	torn := append(append([]byte{}, good...), historyLine(t, 2)[:20]...)
+	path := filepath.Join(dir, segmentPrefix+"00000000000000000001"+segmentSuffix)
+	if err := os.WriteFile(path, torn, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	hs := openHistory(t, dir, 100, 10, 1)
+	if hs.Count() != 2 {
+		t.Fatalf("count %d after repair, want 2", hs.Count())
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, good) {
+		t.Fatalf("torn line was not truncated: %q", data)
+	}
+
+	appendHistory(t, hs, 2, 3)
+	if err := hs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	hs = openHistory(t, dir, 100, 10, 1)
+	defer hs.Close()
+	records, err := hs.Recent(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRecords(t, records, 0, 3)
+}
+
+func TestSegmentHistoryRotatesAndCapsSegments(t *testing.T) {
+	dir := t.TempDir()
+	hs := openHistory(t, dir, 3, 3, 1)
+	defer hs.Close()
+
+	appendHistory(t, hs, 0, 20)
+
+	// Seven segments were written (3 records each, 2 in the last); the
+	// oldest four are gone.
+	lines := segmentLines(t, dir, hs.segmentSize)
+	if len(lines) != 3 {
+		t.Fatalf("%d segments on disk, want 3", len(lines))
+	}
+	onDisk := 0
+	for _, n := range lines {
+		onDisk += n
+	}
+	if onDisk != 8 || hs.Count() != onDisk {
+		t.Fatalf("count %d with %d records on disk, want 8", hs.Count(), onDisk)
+	}
+	records, err := hs.Recent(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRecords(t, records, 12, 20)
+}
+
+func TestSegmentHistoryRecentAcrossSegments(t *testing.T) {
+	dir := t.TempDir()
+	hs := openHistory(t, dir, 100, 100, 50)
+	defer hs.Close()
+
+	// More records than the in-memory tail, ten of them still pending.
+	total := segmentTailCache + 510
+	appendHistory(t, hs, 0, total)
+	if len(hs.pending) != 10 {
+		t.Fatalf("%d records pending, want 10", len(hs.pending))
+	}
+
+	for _, limit := range []int{10, segmentTailCache + 250, total} {
+		records, err := hs.Recent(limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectRecords(t, records, total-limit, total)
+	}
+}
+
+func TestSegmentHistoryReopen(t *testing.T) {
+	dir := t.TempDir()
+	hs := openHistory(t, dir, 10, 10, 1)
+	appendHistory(t, hs, 0, 9)
+	if err := hs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	hs = openHistory(t, dir, 10, 10, 2)
+	defer hs.Close()
+	if hs.Count() != 9 {
+		t.Fatalf("count %d after reopening, want 9", hs.Count())
+	}
+	records, err := hs.Recent(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRecords(t, records, 0, 9)
+
+	// A batch of two no longer fits the nine-line segment.
+	appendHistory(t, hs, 9, 11)
+	lines := segmentLines(t, dir, hs.segmentSize)
+	if len(lines) != 2 || lines[0] != 9 || lines[1] != 2 {
+		t.Fatalf("segment lines %v, want [9 2]", lines)
+	}
+}
@@ -0,0 +1,47 @@
+package terrarium
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	CauseBelowLowerBand = "below_lower_band"
+	CauseAboveUpperBand = "above_upper_band"
+)
+
+// Validate rejects negative band widths and minimum times.
+func (band ControlBand) Validate() error {
+	if band.Lower < 0 || band.Upper < 0 {
+		return fmt.Errorf("lower and upper must not be negative")
+	}
+	if band.MinOnSeconds < 0 || band.MinOffSeconds < 0 {
+		return fmt.Errorf("min_on_seconds and min_off_seconds must not be negative")
+	}
+	return nil
+}
+
+// bandDecision returns the desired actuator state for an actuator that
+// raises value (heater, pump) and the band edge that caused a switch, or an
+// empty cause when the state is unchanged.
+func bandDecision(value, target float32, band ControlBand, on bool, switched, now time.Time) (bool, string) {
+	want, cause := on, ""
+	if on && value > target+band.Upper {
+		want, cause = false, CauseAboveUpperBand
+	} else if !on && value < target-band.Lower {
+		want, cause = true, CauseBelowLowerBand
+	}
+
+	if want == on || switched.IsZero() {
+		return want, cause
+	}
+
+	minHold := band.MinOffSeconds
+	if on {
+		minHold = band.MinOnSeconds
+	}
+	if now.Sub(switched) < time.Duration(minHold)*time.Second {
+		return on, ""
+	}
+	return want, cause
+}
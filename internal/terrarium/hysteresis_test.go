@@ -0,0 +1,61 @@
+package terrarium
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBandDecision(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	band := ControlBand{Lower: 1, Upper: 0.5, MinOnSeconds: 60, MinOffSeconds: 120}
+	tests := []struct {
+		name      string
+		value     float32
+		on        bool
+		switched  time.Duration // before now; 0 means never
+		wantOn    bool
+		wantCause string
+	}{
+		{"inside the band stays off", 25.5, false, time.Hour, false, ""},
+		{"inside the band stays on", 26.4, true, time.Hour, true, ""},
+		{"below the lower edge", 24.9, false, time.Hour, true, CauseBelowLowerBand},
+		{"above the upper edge", 26.6, true, time.Hour, false, CauseAboveUpperBand},
+		{"held off for min_off", 24.9, false, time.Minute, false, ""},
+		{"held on for min_on", 26.6, true, 30 * time.Second, true, ""},
+		{"first switch ignores minimums", 24.9, false, 0, true, CauseBelowLowerBand},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var switched time.Time
+			if tt.switched > 0 {
+				switched = now.Add(-tt.switched)
+			}
+			on, cause := bandDecision(tt.value, 26, band, tt.on, switched, now)
+			if on != tt.wantOn || cause != tt.wantCause {
+				t.Errorf("got %v %q, want %v %q", on, cause, tt.wantOn, tt.wantCause)
+			}
+		})
+	}
+}
+
+func TestControlBandValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		band    ControlBand
+		wantErr bool
+	}{
+		{"zero band", ControlBand{}, false},
+		{"typical", ControlBand{Lower: 0.5, Upper: 0.5, MinOnSeconds: 60, MinOffSeconds: 60}, false},
+		{"negative lower", ControlBand{Lower: -1}, true},
+		{"negative upper", ControlBand{Upper: -0.5}, true},
+		{"negative min_on", ControlBand{MinOnSeconds: -1}, true},
+		{"negative min_off", ControlBand{MinOffSeconds: -30}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.band.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package terrarium
+
+import (
+	"sync"
+	"time"
+
+	"github.com/undeadpelmen/new-client/internal/metrics"
+	"github.com/undeadpelmen/new-client/internal/sensor"
+)
+
+// Sensor sources counted in SensorFailures.
+const (
+	SensorSourceClimate = "climate"
+	SensorSourceProbe   = "ds18b20"
+)
+
+// SensorFailureKey identifies a sensor read failure counter.
+type SensorFailureKey struct {
+	Source string
+	Type   string
+}
+
+// ControllerMetrics is a snapshot of the counters kept by the controller.
+type ControllerMetrics struct {
+	Cycles         int64
+	SensorFailures map[SensorFailureKey]int64
+	RelaySwitches  map[string]int64
+	CycleDuration  metrics.HistogramSnapshot
+}
+
+type controllerCounters struct {
+	mu             sync.Mutex
+	cycles         int64
+	sensorFailures map[SensorFailureKey]int64
+	relaySwitches  map[string]int64
+	cycleDuration  *metrics.Histogram
+}
+
+func newControllerCounters() *controllerCounters {
+	c := &controllerCounters{
+		sensorFailures: make(map[SensorFailureKey]int64),
+		relaySwitches:  map[string]int64{RelayLight: 0, RelayHeater: 0, RelayPump: 0},
+		// Cycles mostly wait on the sensor: a DHT22 retry alone takes 2 s.
+		cycleDuration: metrics.NewHistogram(0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10),
+	}
+	// Export every failure type from the start so rates work from zero.
+	for _, source := range []string{SensorSourceClimate, SensorSourceProbe} {
+		for _, failure := range []string{sensor.FailureTimeout, sensor.FailureChecksum, sensor.FailureRange, sensor.FailureOther} {
+			c.sensorFailures[SensorFailureKey{Source: source, Type: failure}] = 0
+		}
+	}
+	return c
+}
+
+func (c *controllerCounters) cycle(duration time.Duration) {
+	c.mu.Lock()
+	c.cycles++
+	c.mu.Unlock()
+	c.cycleDuration.Observe(duration.Seconds())
+}
+
+func (c *controllerCounters) sensorFailure(source string, err error) {
+	c.mu.Lock()
+	c.sensorFailures[SensorFailureKey{Source: source, Type: sensor.FailureType(err)}]++
+	c.mu.Unlock()
+}
+
+func (c *controllerCounters) relaySwitch(relay string) {
+	c.mu.Lock()
+	c.relaySwitches[relay]++
+	c.mu.Unlock()
+}
+
+// Metrics returns the controller's counters since startup.
+func (tc *TerrariumController) Metrics() ControllerMetrics {
+	c := tc.counters
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snapshot := ControllerMetrics{
+		Cycles:         c.cycles,
+		SensorFailures: make(map[SensorFailureKey]int64, len(c.sensorFailures)),
+		RelaySwitches:  make(map[string]int64, len(c.relaySwitches)),
+		CycleDuration:  c.cycleDuration.Snapshot(),
+	}
+	for key, count := range c.sensorFailures {
+		snapshot.SensorFailures[key] = count
+	}
+	for relay, count := range c.relaySwitches {
+		snapshot.RelaySwitches[relay] = count
+	}
+	return snapshot
+}
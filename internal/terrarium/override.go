@@ -0,0 +1,143 @@
+package terrarium
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	RelayLight  = "light"
+	RelayHeater = "heater"
+	RelayPump   = "pump"
+
+	CauseManualOverride = "manual_override"
+
+	// MaxPumpOverride bounds a manual pump run, so a forgotten or
+	// repeated "on" cannot flood the enclosure.
+	MaxPumpOverride = 10 * time.Minute
+)
+
+// RelayOverride pins a relay to a state until Expires; a nil Expires
+// holds until the override is cancelled. Switching the pump on always
+// expires, after at most MaxPumpOverride.
+type RelayOverride struct {
+	State   bool       `json:"state"`
+	Since   time.Time  `json:"since"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+func (tc *TerrariumController) SetOverride(relay string, state bool, duration time.Duration) error {
+	tc.cycleMu.Lock()
+	defer tc.cycleMu.Unlock()
+
+	if relay == RelayPump && state && (duration <= 0 || duration > MaxPumpOverride) {
+		duration = MaxPumpOverride
+	}
+
+	now := tc.clock.Now()
+	override := RelayOverride{State: state, Since: now}
+	if duration > 0 {
+		expires := now.Add(duration)
+		override.Expires = &expires
+	}
+
+	var err error
+	switch relay {
+	case RelayLight:
+		err = tc.relays.SetLight(state)
+	case RelayHeater:
+		err = tc.relays.SetHeater(state)
+	case RelayPump:
+		tc.cancelPumpPulse()
+		err = tc.relays.SetPump(state)
+	default:
+		return fmt.Errorf("unknown relay %q", relay)
+	}
+	if err != nil {
+		return fmt.Errorf("%s switch failed: %v", relay, err)
+	}
+
+	tc.terrarium.UpdateState(func(s *TerrariumState) {
+		switch relay {
+		case RelayLight:
+			s.LightRelay = state
+		case RelayHeater:
+			s.HeaterRelay = state
+			s.HeaterSwitched = now
+		case RelayPump:
+			s.PumpRelay = state
+			s.PumpSwitched = now
+			if state {
+				s.LastPumpRun = now
+			}
+		}
+		overrides := copyOverrides(s.Overrides)
+		overrides[relay] = override
+		s.Overrides = overrides
+	})
+
+	if duration > 0 {
+		log.Printf("Manual override: %s -> %v for %v", relay, state, duration)
+	} else {
+		log.Printf("Manual override: %s -> %v until cancelled", relay, state)
+	}
+	return nil
+}
+
+// ClearOverride returns the relay to automatic control on the next cycle.
+func (tc *TerrariumController) ClearOverride(relay string) bool {
+	tc.cycleMu.Lock()
+	defer tc.cycleMu.Unlock()
+
+	var found bool
+	tc.terrarium.UpdateState(func(s *TerrariumState) {
+		if _, found = s.Overrides[relay]; found {
+			overrides := copyOverrides(s.Overrides)
+			delete(overrides, relay)
+			s.Overrides = overrides
+		}
+	})
+	if found {
+		log.Printf("Manual override on %s cancelled", relay)
+	}
+	return found
+}
+
+func (tc *TerrariumController) activeOverride(relay string) (bool, bool) {
+	var override RelayOverride
+	var ok bool
+	tc.terrarium.UpdateState(func(s *TerrariumState) {
+		override, ok = s.Overrides[relay]
+	})
+	return override.State, ok
+}
+
+func (tc *TerrariumController) expireOverrides() {
+	now := tc.clock.Now()
+	tc.terrarium.UpdateState(func(s *TerrariumState) {
+		var expired []string
+		for relay, override := range s.Overrides {
+			if override.Expires != nil && !now.Before(*override.Expires) {
+				expired = append(expired, relay)
+			}
+		}
+		if len(expired) == 0 {
+			return
+		}
+		overrides := copyOverrides(s.Overrides)
+		for _, relay := range expired {
+			delete(overrides, relay)
+			log.Printf("Manual override on %s expired, returning to automatic control", relay)
+		}
+		s.Overrides = overrides
+	})
+}
+
+func copyOverrides(src map[string]RelayOverride) map[string]RelayOverride {
+	dst := make(map[string]RelayOverride, len(src)+1)
+	for relay, override := range src {
+		dst[relay] = override
+	}
+	return dst
+}
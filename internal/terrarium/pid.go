@@ -0,0 +1,117 @@
+package terrarium
+
+import (
+	"fmt"
+	"time"
+)
+
+const CausePIDWindow = "pid_window"
+
+type PIDSettings struct {
+	Enabled       bool    `json:"enabled"`
+	Kp            float32 `json:"kp"`
+	Ki            float32 `json:"ki"`
+	Kd            float32 `json:"kd"`
+	WindowSeconds int     `json:"window_seconds"`
+	IntegralMin   float32 `json:"integral_min"`
+	IntegralMax   float32 `json:"integral_max"`
+}
+
+// Validate rejects negative gains, an empty time-proportioning window and
+// integral limits that exclude each other.
+func (cfg PIDSettings) Validate() error {
+	if cfg.Kp < 0 || cfg.Ki < 0 || cfg.Kd < 0 {
+		return fmt.Errorf("kp, ki and kd must not be negative")
+	}
+	if cfg.WindowSeconds <= 0 {
+		return fmt.Errorf("window_seconds must be positive")
+	}
+	if cfg.IntegralMin > cfg.IntegralMax {
+		return fmt.Errorf("integral_min %.2f is above integral_max %.2f", cfg.IntegralMin, cfg.IntegralMax)
+	}
+	return nil
+}
+
+type PIDTerms struct {
+	Output float32 `json:"output"`
+	P      float32 `json:"p"`
+	I      float32 `json:"i"`
+	D      float32 `json:"d"`
+}
+
+// PIDController computes a heater duty cycle in the range 0..1 and turns it
+// into on/off decisions over a fixed time-proportioning window.
+type PIDController struct {
+	integral     float32
+	prevMeasured float32
+	lastUpdate   time.Time
+	windowStart  time.Time
+	terms        PIDTerms
+}
+
+func NewPIDController() *PIDController {
+	return &PIDController{}
+}
+
+func (pid *PIDController) Reset() {
+	*pid = PIDController{}
+}
+
+func (pid *PIDController) Update(setpoint, measured float32, cfg PIDSettings, now time.Time) PIDTerms {
+	errValue := setpoint - measured
+
+	var dt float32
+	if !pid.lastUpdate.IsZero() {
+		dt = float32(now.Sub(pid.lastUpdate).Seconds())
+	}
+
+	p := cfg.Kp * errValue
+
+	// Anti-windup: the integral contribution is clamped to its limits and
+	// the accumulator is back-calculated so it cannot grow past them.
+	i := pid.terms.I
+	if dt > 0 {
+		pid.integral += errValue * dt
+		i = cfg.Ki * pid.integral
+		if i > cfg.IntegralMax {
+			i = cfg.IntegralMax
+		} else if i < cfg.IntegralMin {
+			i = cfg.IntegralMin
+		}
+		if cfg.Ki != 0 {
+			pid.integral = i / cfg.Ki
+		}
+	}
+
+	// Derivative on measurement avoids a kick when the setpoint changes.
+	var d float32
+	if dt > 0 {
+		d = -cfg.Kd * (measured - pid.prevMeasured) / dt
+	}
+
+	output := p + i + d
+	if output > 1 {
+		output = 1
+	} else if output < 0 {
+		output = 0
+	}
+
+	pid.prevMeasured = measured
+	pid.lastUpdate = now
+	pid.terms = PIDTerms{Output: output, P: p, I: i, D: d}
+	return pid.terms
+}
+
+// HeaterOn reports whether the relay should be energized at now, given the
+// last computed output and the window length.
+func (pid *PIDController) HeaterOn(windowSeconds int, now time.Time) bool {
+	window := time.Duration(windowSeconds) * time.Second
+	if window <= 0 {
+		return pid.terms.Output >= 0.5
+	}
+	if pid.windowStart.IsZero() || now.Sub(pid.windowStart) >= window {
+		pid.windowStart = now
+	}
+	onFor := time.Duration(float64(pid.terms.Output) * float64(window))
+	return now.Sub(pid.windowStart) < onFor
+}
@@ -0,0 +1,76 @@
+package terrarium
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPIDIntegralIsClamped(t *testing.T) {
+	cfg := PIDSettings{Kp: 0.1, Ki: 0.01, WindowSeconds: 60, IntegralMin: 0, IntegralMax: 0.3}
+	pid := NewPIDController()
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// An hour far below the setpoint must not wind the integral past
+	// its limit, so the output drops as soon as the error flips.
+	var terms PIDTerms
+	for range 60 {
+		terms = pid.Update(30, 20, cfg, now)
+		now = now.Add(time.Minute)
+	}
+	if terms.I != cfg.IntegralMax || terms.Output != 1 {
+		t.Fatalf("terms %+v after an hour below target, want I=%.2f and full output", terms, cfg.IntegralMax)
+	}
+
+	terms = pid.Update(30, 31, cfg, now)
+	if terms.Output > cfg.IntegralMax {
+		t.Errorf("output %.2f just above target, want at most the integral limit %.2f", terms.Output, cfg.IntegralMax)
+	}
+}
+
+func TestPIDHeaterOnFollowsDutyCycle(t *testing.T) {
+	pid := NewPIDController()
+	pid.terms.Output = 0.25
+	start := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, tt := range []struct {
+		offset time.Duration
+		want   bool
+	}{
+		{0, true},
+		{14 * time.Second, true},
+		{15 * time.Second, false},
+		{59 * time.Second, false},
+		{60 * time.Second, true}, // next window
+	} {
+		if got := pid.HeaterOn(60, start.Add(tt.offset)); got != tt.want {
+			t.Errorf("at %v heater on = %v, want %v", tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestPIDSettingsValidate(t *testing.T) {
+	valid := PIDSettings{Kp: 0.5, Ki: 0.005, WindowSeconds: 60, IntegralMax: 0.5}
+	tests := []struct {
+		name    string
+		change  func(*PIDSettings)
+		wantErr bool
+	}{
+		{"valid", func(*PIDSettings) {}, false},
+		{"equal integral limits", func(p *PIDSettings) { p.IntegralMin = 0.5 }, false},
+		{"negative kp", func(p *PIDSettings) { p.Kp = -0.1 }, true},
+		{"negative ki", func(p *PIDSettings) { p.Ki = -0.001 }, true},
+		{"negative kd", func(p *PIDSettings) { p.Kd = -1 }, true},
+		{"zero window", func(p *PIDSettings) { p.WindowSeconds = 0 }, true},
+		{"negative window", func(p *PIDSettings) { p.WindowSeconds = -60 }, true},
+		{"integral limits swapped", func(p *PIDSettings) { p.IntegralMin, p.IntegralMax = 0.5, 0 }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.change(&cfg)
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package terrarium
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const CausePumpCooldown = "cooldown"
+
+// PumpSettings configures misting. DurationSeconds is the length of one
+// pulse, zero running the pump continuously within the humidity band;
+// MinInterval is the pause in minutes between pulses.
+type PumpSettings struct {
+	DurationSeconds int `json:"duration_seconds"`
+	MinInterval     int `json:"min_interval"`
+}
+
+func (p PumpSettings) Validate() error {
+	if p.DurationSeconds < 0 {
+		return fmt.Errorf("duration_seconds must not be negative")
+	}
+	if p.MinInterval < 0 {
+		return fmt.Errorf("min_interval must not be negative")
+	}
+	return nil
+}
+
+// controlPumpPulse runs the misting mode: when humidity drops below the
+// lower band the pump fires for PumpSettings.DurationSeconds on its own
+// timer, then may not fire again until PumpSettings.MinInterval minutes
+// after LastPumpRun.
+func (tc *TerrariumController) controlPumpPulse(humidity, target float32, settings *TerrariumSettings, now time.Time) (bool, string) {
+	if tc.pulseRunning() {
+		return true, ""
+	}
+
+	var lastRun, switched time.Time
+	tc.terrarium.UpdateState(func(s *TerrariumState) {
+		lastRun = s.LastPumpRun
+		switched = s.PumpSwitched
+	})
+
+	want, cause := bandDecision(humidity, target,
+		settings.Targets.HumidityBand, false, switched, now)
+	if !want {
+		return false, ""
+	}
+
+	cooldown := time.Duration(settings.PumpSettings.MinInterval) * time.Minute
+	if !lastRun.IsZero() && now.Sub(lastRun) < cooldown {
+		return false, CausePumpCooldown
+	}
+
+	duration := time.Duration(settings.PumpSettings.DurationSeconds) * time.Second
+	if !tc.startPumpPulse(duration) {
+		return false, ""
+	}
+	log.Printf("Pump pulse started for %v (H=%.1f, %s of %.1f)",
+		duration, humidity, cause, target)
+	return true, cause
+}
+
+func (tc *TerrariumController) startPumpPulse(duration time.Duration) bool {
+	tc.pumpMu.Lock()
+	defer tc.pumpMu.Unlock()
+
+	if err := tc.relays.SetPump(true); err != nil {
+		log.Printf("Pump turn-on error: %v", err)
+		return false
+	}
+
+	now := tc.clock.Now()
+	tc.terrarium.UpdateState(func(s *TerrariumState) {
+		s.PumpRelay = true
+		s.PumpSwitched = now
+		s.LastPumpRun = now
+	})
+
+	if tc.pumpTimer != nil {
+		tc.pumpTimer.Stop()
+	}
+	tc.pumpTimer = tc.clock.AfterFunc(duration, tc.stopPumpPulse)
+	return true
+}
+
+// pulseRunning reports whether a pulse timer is pending. A pump that is on
+// without one was left running by an override or by continuous mode, and
+// nothing would ever stop it, so it is switched off here.
+func (tc *TerrariumController) pulseRunning() bool {
+	tc.pumpMu.Lock()
+	defer tc.pumpMu.Unlock()
+
+	if tc.pumpTimer != nil {
+		return true
+	}
+	var on bool
+	tc.terrarium.UpdateState(func(s *TerrariumState) {
+		on = s.PumpRelay
+	})
+	if on {
+		log.Println("Pump on without a pulse timer, switching it off")
+		tc.pumpOffLocked()
+	}
+	return false
+}
+
+func (tc *TerrariumController) stopPumpPulse() {
+	tc.pumpMu.Lock()
+	defer tc.pumpMu.Unlock()
+
+	tc.pumpTimer = nil
+	tc.pumpOffLocked()
+	log.Println("Pump pulse finished")
+}
+
+func (tc *TerrariumController) pumpOffLocked() {
+	if err := tc.relays.SetPump(false); err != nil {
+		log.Printf("Pump turn-off error: %v", err)
+	}
+	tc.terrarium.UpdateState(func(s *TerrariumState) {
+		s.PumpRelay = false
+		s.PumpSwitched = tc.clock.Now()
+	})
+}
+
+// cancelPumpPulse stops a pending pulse timer without touching the relay.
+func (tc *TerrariumController) cancelPumpPulse() {
+	tc.pumpMu.Lock()
+	defer tc.pumpMu.Unlock()
+
+	if tc.pumpTimer != nil {
+		tc.pumpTimer.Stop()
+		tc.pumpTimer = nil
+	}
+}
@@ -0,0 +1,128 @@
+package terrarium
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/undeadpelmen/new-client/internal/gpio"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func newTestController(t *testing.T) (*TerrariumController, *ManualClock) {
+	t.Helper()
+	terrarium := NewTerrarium()
+	terrarium.UpdateSettings(func(s *TerrariumSettings) {
+		s.UseMockData = true
+	})
+	clock := NewManualClock(time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC))
+	tc := NewTerrariumController(terrarium, gpio.NewSimulatedRelays())
+	tc.SetClock(clock)
+	return tc, clock
+}
+
+// runFor steps the controller on its manual clock for d.
+func runFor(tc *TerrariumController, clock *ManualClock, d time.Duration) {
+	end := clock.Now().Add(d)
+	for clock.Now().Before(end) {
+		pause := tc.Step()
+		if pause <= 0 {
+			pause = time.Second
+		}
+		clock.Advance(pause)
+	}
+}
+
+func pumpOn(tc *TerrariumController) bool {
+	return tc.terrarium.GetState().PumpRelay
+}
+
+func TestPumpOverrideEndsInPulseMode(t *testing.T) {
+	tc, clock := newTestController(t)
+	tc.terrarium.UpdateSettings(func(s *TerrariumSettings) {
+		s.PumpSettings.DurationSeconds = 10
+		s.Targets.Humidity = 0
+	})
+
+	if err := tc.SetOverride(RelayPump, true, 0); err != nil {
+		t.Fatal(err)
+	}
+	runFor(tc, clock, time.Minute)
+	if !pumpOn(tc) {
+		t.Fatal("pump should run while the override holds")
+	}
+
+	tc.ClearOverride(RelayPump)
+	runFor(tc, clock, time.Minute)
+	if pumpOn(tc) {
+		t.Fatal("pump still on after the override was cleared")
+	}
+}
+
+func TestPumpOverrideIsBounded(t *testing.T) {
+	tc, clock := newTestController(t)
+	tc.terrarium.UpdateSettings(func(s *TerrariumSettings) {
+		s.Targets.Humidity = 0
+	})
+
+	if err := tc.SetOverride(RelayPump, true, 0); err != nil {
+		t.Fatal(err)
+	}
+	override := tc.terrarium.GetState().Overrides[RelayPump]
+	if override.Expires == nil || override.Expires.Sub(override.Since) != MaxPumpOverride {
+		t.Fatalf("pump override expires %v, want after %v", override.Expires, MaxPumpOverride)
+	}
+
+	runFor(tc, clock, MaxPumpOverride+time.Minute)
+	if pumpOn(tc) {
+		t.Fatal("pump still on after the override bound")
+	}
+}
+
+func TestPumpSwitchToPulseModeStopsContinuousRun(t *testing.T) {
+	tc, clock := newTestController(t)
+	tc.terrarium.UpdateSettings(func(s *TerrariumSettings) {
+		s.PumpSettings.DurationSeconds = 0
+		s.Targets.Humidity = 100
+	})
+
+	runFor(tc, clock, time.Minute)
+	if !pumpOn(tc) {
+		t.Fatal("continuous mode should run the pump below the humidity band")
+	}
+
+	tc.terrarium.UpdateSettings(func(s *TerrariumSettings) {
+		s.PumpSettings.DurationSeconds = 10
+		s.Targets.Humidity = 0
+	})
+	runFor(tc, clock, time.Minute)
+	if pumpOn(tc) {
+		t.Fatal("pump still on after switching to pulse mode")
+	}
+}
+
+func TestPumpSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings PumpSettings
+		wantErr  bool
+	}{
+		{"continuous", PumpSettings{}, false},
+		{"pulses", PumpSettings{DurationSeconds: 5, MinInterval: 30}, false},
+		{"negative duration", PumpSettings{DurationSeconds: -1, MinInterval: 30}, true},
+		{"negative interval", PumpSettings{DurationSeconds: 5, MinInterval: -30}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.settings.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package terrarium
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/undeadpelmen/new-client/internal/astro"
+)
+
+const minutesPerWeek = 7 * 24 * 60
+
+// Light schedule modes: fixed follows Windows, solar follows sunrise and
+// sunset at the configured position.
+const (
+	LightModeFixed = "fixed"
+	LightModeSolar = "solar"
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// LightWindow switches the light on from Start to End (HH:MM, end
+// exclusive) on the listed days, or every day when Days is empty. A window
+// whose end is before its start runs past midnight and belongs to the day
+// it starts on.
+type LightWindow struct {
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+	Days  []string `json:"days,omitempty"`
+}
+
+func parseClock(value string) (int, error) {
+	var hour, minute int
+	n, err := fmt.Sscanf(value, "%d:%d", &hour, &minute)
+	if err != nil || n != 2 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("time %q out of range", value)
+	}
+	return hour*60 + minute, nil
+}
+
+func (w LightWindow) weekdays() ([]time.Weekday, error) {
+	if len(w.Days) == 0 {
+		return []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday,
+			time.Thursday, time.Friday, time.Saturday}, nil
+	}
+	days := make([]time.Weekday, 0, len(w.Days))
+	for _, name := range w.Days {
+		day, ok := weekdayNames[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %q", name)
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// weekMinutes returns the [start, end) minute ranges of the window within a
+// week starting Sunday 00:00; ranges may extend past the end of the week.
+func (w LightWindow) weekMinutes() ([][2]int, error) {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return nil, err
+	}
+	if start == end {
+		return nil, fmt.Errorf("window %s-%s is empty", w.Start, w.End)
+	}
+	if end < start {
+		end += 24 * 60
+	}
+
+	days, err := w.weekdays()
+	if err != nil {
+		return nil, err
+	}
+	ranges := make([][2]int, 0, len(days))
+	for _, day := range days {
+		offset := int(day) * 24 * 60
+		ranges = append(ranges, [2]int{offset + start, offset + end})
+	}
+	return ranges, nil
+}
+
+// ValidateLightWindows rejects malformed windows and windows that overlap
+// on any day.
+func ValidateLightWindows(windows []LightWindow) error {
+	var week [minutesPerWeek]int8
+	for i, window := range windows {
+		ranges, err := window.weekMinutes()
+		if err != nil {
+			return fmt.Errorf("window %d: %v", i, err)
+		}
+		for _, r := range ranges {
+			for m := r[0]; m < r[1]; m++ {
+				slot := m % minutesPerWeek
+				if week[slot] != 0 {
+					return fmt.Errorf("window %d (%s-%s) overlaps window %d", i, window.Start, window.End, week[slot]-1)
+				}
+				week[slot] = int8(i + 1)
+			}
+		}
+	}
+	return nil
+}
+
+func lightWindowsActive(windows []LightWindow, now time.Time) bool {
+	minute := int(now.Weekday())*24*60 + now.Hour()*60 + now.Minute()
+	for _, window := range windows {
+		ranges, err := window.weekMinutes()
+		if err != nil {
+			continue
+		}
+		for _, r := range ranges {
+			// Check both this week and the tail of last Saturday's window.
+			if (minute >= r[0] && minute < r[1]) || (minute+minutesPerWeek >= r[0] && minute+minutesPerWeek < r[1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// migrateLightSchedule converts the legacy single StartTime/EndTime pair
+// into a daily window.
+func migrateLightSchedule(s *TerrariumSettings) {
+	if s.LightSchedule.StartTime == "" && s.LightSchedule.EndTime == "" {
+		return
+	}
+	s.LightSchedule.Windows = []LightWindow{{
+		Start: s.LightSchedule.StartTime,
+		End:   s.LightSchedule.EndTime,
+	}}
+	s.LightSchedule.StartTime = ""
+	s.LightSchedule.EndTime = ""
+}
+
+// SolarSchedule turns the light on at sunrise and off at sunset for a
+// position in degrees (north and east positive), each shifted by an offset
+// in minutes; a positive offset is later.
+type SolarSchedule struct {
+	Latitude             float64 `json:"latitude"`
+	Longitude            float64 `json:"longitude"`
+	SunriseOffsetMinutes int     `json:"sunrise_offset_minutes"`
+	SunsetOffsetMinutes  int     `json:"sunset_offset_minutes"`
+}
+
+// SolarDay is the solar schedule for one calendar day. Sunrise and Sunset
+// are nil during polar day or night; LightOn and LightOff are nil when the
+// light stays off all day.
+type SolarDay struct {
+	Date     string     `json:"date"`
+	DayType  string     `json:"day_type"`
+	Sunrise  *time.Time `json:"sunrise,omitempty"`
+	Sunset   *time.Time `json:"sunset,omitempty"`
+	LightOn  *time.Time `json:"light_on,omitempty"`
+	LightOff *time.Time `json:"light_off,omitempty"`
+}
+
+const maxSolarOffsetMinutes = 6 * 60
+
+func (s SolarSchedule) Validate() error {
+	if s.Latitude < -90 || s.Latitude > 90 {
+		return fmt.Errorf("latitude %v out of range", s.Latitude)
+	}
+	if s.Longitude < -180 || s.Longitude > 180 {
+		return fmt.Errorf("longitude %v out of range", s.Longitude)
+	}
+	for _, offset := range []int{s.SunriseOffsetMinutes, s.SunsetOffsetMinutes} {
+		if offset < -maxSolarOffsetMinutes || offset > maxSolarOffsetMinutes {
+			return fmt.Errorf("offset %d minutes exceeds %d", offset, maxSolarOffsetMinutes)
+		}
+	}
+	return nil
+}
+
+// Day computes the schedule for the calendar day of date in its location.
+// The light stays on all day during polar day and off during polar night.
+func (s SolarSchedule) Day(date time.Time) SolarDay {
+	sunrise, sunset, dayType := astro.SunTimes(date, s.Latitude, s.Longitude)
+	day := SolarDay{Date: date.Format(time.DateOnly)}
+
+	switch dayType {
+	case astro.PolarNight:
+		day.DayType = "polar_night"
+	case astro.PolarDay:
+		day.DayType = "polar_day"
+		y, m, d := date.Date()
+		on := time.Date(y, m, d, 0, 0, 0, 0, date.Location())
+		off := on.AddDate(0, 0, 1)
+		day.LightOn, day.LightOff = &on, &off
+	default:
+		day.DayType = "normal"
+		on := sunrise.Add(time.Duration(s.SunriseOffsetMinutes) * time.Minute)
+		off := sunset.Add(time.Duration(s.SunsetOffsetMinutes) * time.Minute)
+		day.Sunrise, day.Sunset = &sunrise, &sunset
+		if on.Before(off) {
+			day.LightOn, day.LightOff = &on, &off
+		}
+	}
+	return day
+}
+
+// Active reports whether the light should be on at now. Yesterday is also
+// checked so a sunset offset past midnight keeps the light on.
+func (s SolarSchedule) Active(now time.Time) bool {
+	for _, date := range []time.Time{now, now.AddDate(0, 0, -1)} {
+		day := s.Day(date)
+		if day.LightOn != nil && !now.Before(*day.LightOn) && now.Before(*day.LightOff) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateLightMode rejects unknown light schedule modes.
+func ValidateLightMode(mode string) error {
+	switch mode {
+	case LightModeFixed, LightModeSolar:
+		return nil
+	}
+	return fmt.Errorf("unknown light mode %q (expected %s or %s)", mode, LightModeFixed, LightModeSolar)
+}
@@ -0,0 +1,189 @@
+package terrarium
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+const daysPerSeasonYear = 365
+
+// SeasonKeyframe anchors targets to a calendar date (MM-DD) that repeats
+// every year. LightHours sets the photoperiod of the fixed light schedule;
+// zero leaves the light schedule alone.
+type SeasonKeyframe struct {
+	Name        string  `json:"name"`
+	Date        string  `json:"date"`
+	Temperature float32 `json:"temperature"`
+	Humidity    float32 `json:"humidity"`
+	LightHours  float32 `json:"light_hours,omitempty"`
+}
+
+// SeasonalProfile replaces Targets.Temperature and Targets.Humidity with
+// values interpolated day by day between keyframes. Seasonal photoperiods
+// are centered on LightCenter (HH:MM).
+type SeasonalProfile struct {
+	Enabled     bool             `json:"enabled"`
+	LightCenter string           `json:"light_center"`
+	Keyframes   []SeasonKeyframe `json:"keyframes"`
+}
+
+// SeasonStatus is the profile evaluated for one day. Season is the last
+// keyframe passed, Next the one being approached and Progress how far the
+// transition has come, from 0 to 1.
+type SeasonStatus struct {
+	Season      string  `json:"season"`
+	Next        string  `json:"next"`
+	Progress    float32 `json:"progress"`
+	Temperature float32 `json:"temperature"`
+	Humidity    float32 `json:"humidity"`
+	LightHours  float32 `json:"light_hours,omitempty"`
+	LightOn     string  `json:"light_on,omitempty"`
+	LightOff    string  `json:"light_off,omitempty"`
+}
+
+// seasonDay maps a month and day to a day index in a non-leap year;
+// 29 February shares the index of 28 February.
+func seasonDay(month time.Month, day int) int {
+	if month == time.February && day == 29 {
+		day = 28
+	}
+	return time.Date(2001, month, day, 0, 0, 0, 0, time.UTC).YearDay() - 1
+}
+
+func parseSeasonDate(value string) (int, error) {
+	var month, day int
+	n, err := fmt.Sscanf(value, "%d-%d", &month, &day)
+	if err != nil || n != 2 {
+		return 0, fmt.Errorf("invalid date %q, expected MM-DD", value)
+	}
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return 0, fmt.Errorf("date %q out of range", value)
+	}
+	if normalized := time.Date(2004, time.Month(month), day, 0, 0, 0, 0, time.UTC); normalized.Day() != day {
+		return 0, fmt.Errorf("date %q does not exist", value)
+	}
+	return seasonDay(time.Month(month), day), nil
+}
+
+// ValidateSeasons rejects malformed keyframes and duplicate dates.
+func ValidateSeasons(profile SeasonalProfile) error {
+	if profile.LightCenter != "" {
+		if _, err := parseClock(profile.LightCenter); err != nil {
+			return fmt.Errorf("light center: %v", err)
+		}
+	}
+	if profile.Enabled && len(profile.Keyframes) == 0 {
+		return fmt.Errorf("enabled profile has no keyframes")
+	}
+	seen := make(map[int]string, len(profile.Keyframes))
+	for i, keyframe := range profile.Keyframes {
+		day, err := parseSeasonDate(keyframe.Date)
+		if err != nil {
+			return fmt.Errorf("keyframe %d: %v", i, err)
+		}
+		if other, ok := seen[day]; ok {
+			return fmt.Errorf("keyframe %d: date %s already used by %q", i, keyframe.Date, other)
+		}
+		seen[day] = keyframe.Name
+		if keyframe.Humidity < 0 || keyframe.Humidity > 100 {
+			return fmt.Errorf("keyframe %d: humidity %.1f out of range", i, keyframe.Humidity)
+		}
+		if keyframe.LightHours < 0 || keyframe.LightHours > 24 {
+			return fmt.Errorf("keyframe %d: light hours %.1f out of range", i, keyframe.LightHours)
+		}
+	}
+	return nil
+}
+
+type seasonAnchor struct {
+	day      int
+	keyframe SeasonKeyframe
+}
+
+// At evaluates the profile for the calendar day of now. It reports false
+// when the profile is disabled or has no valid keyframes.
+func (p SeasonalProfile) At(now time.Time) (SeasonStatus, bool) {
+	if !p.Enabled {
+		return SeasonStatus{}, false
+	}
+	anchors := make([]seasonAnchor, 0, len(p.Keyframes))
+	for _, keyframe := range p.Keyframes {
+		day, err := parseSeasonDate(keyframe.Date)
+		if err != nil {
+			continue
+		}
+		anchors = append(anchors, seasonAnchor{day: day, keyframe: keyframe})
+	}
+	if len(anchors) == 0 {
+		return SeasonStatus{}, false
+	}
+	slices.SortFunc(anchors, func(a, b seasonAnchor) int { return a.day - b.day })
+
+	today := seasonDay(now.Month(), now.Day())
+	// The previous keyframe is the last one on or before today, wrapping
+	// to the end of last year.
+	prev := len(anchors) - 1
+	for i, anchor := range anchors {
+		if anchor.day <= today {
+			prev = i
+		}
+	}
+	next := (prev + 1) % len(anchors)
+	from, to := anchors[prev], anchors[next]
+
+	span := (to.day - from.day + daysPerSeasonYear) % daysPerSeasonYear
+	if span == 0 {
+		span = daysPerSeasonYear
+	}
+	elapsed := (today - from.day + daysPerSeasonYear) % daysPerSeasonYear
+	progress := float32(elapsed) / float32(span)
+	lerp := func(a, b float32) float32 { return a + (b-a)*progress }
+
+	status := SeasonStatus{
+		Season:      from.keyframe.Name,
+		Next:        to.keyframe.Name,
+		Progress:    progress,
+		Temperature: lerp(from.keyframe.Temperature, to.keyframe.Temperature),
+		Humidity:    lerp(from.keyframe.Humidity, to.keyframe.Humidity),
+	}
+	if from.keyframe.LightHours > 0 && to.keyframe.LightHours > 0 {
+		status.LightHours = lerp(from.keyframe.LightHours, to.keyframe.LightHours)
+		status.LightOn, status.LightOff = p.photoperiod(status.LightHours)
+	}
+	return status, true
+}
+
+// photoperiod centers a window of the given length on LightCenter,
+// defaulting to 13:00.
+func (p SeasonalProfile) photoperiod(hours float32) (string, string) {
+	center, err := parseClock(p.LightCenter)
+	if err != nil {
+		center = 13 * 60
+	}
+	half := int(hours*60) / 2
+	on := (center - half + 24*60) % (24 * 60)
+	off := (center + half) % (24 * 60)
+	return fmt.Sprintf("%02d:%02d", on/60, on%60), fmt.Sprintf("%02d:%02d", off/60, off%60)
+}
+
+// seasonalLightActive reports whether now falls within the seasonal
+// photoperiod. A full 24 hour photoperiod keeps the light on.
+func seasonalLightActive(status SeasonStatus, now time.Time) bool {
+	if status.LightHours >= 24 {
+		return true
+	}
+	on, err := parseClock(status.LightOn)
+	if err != nil {
+		return false
+	}
+	off, err := parseClock(status.LightOff)
+	if err != nil || on == off {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if on < off {
+		return minute >= on && minute < off
+	}
+	return minute >= on || minute < off
+}
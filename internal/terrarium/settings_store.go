@@ -0,0 +1,45 @@
+package terrarium
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/undeadpelmen/new-client/internal/atomicfile"
+)
+
+type SettingsStore struct {
+	path string
+}
+
+func NewSettingsStore(path string) *SettingsStore {
+	return &SettingsStore{path: path}
+}
+
+func (ss *SettingsStore) Path() string {
+	return ss.path
+}
+
+func (ss *SettingsStore) Read() ([]byte, error) {
+	data, err := os.ReadFile(ss.path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode into a scratch value first so a corrupt file never leaves
+	// the live settings half-overwritten.
+	var probe TerrariumSettings
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("corrupt settings file %s: %v", ss.path, err)
+	}
+	return data, nil
+}
+
+func (ss *SettingsStore) Write(settings *TerrariumSettings) error {
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode settings: %v", err)
+	}
+	// The file holds password hashes and notifier credentials.
+	return atomicfile.Write(ss.path, data, 0o600)
+}
@@ -7,97 +7,198 @@ import (
 	"sync"
 	"time"
 
+	"github.com/undeadpelmen/new-client/internal/alert"
 	"github.com/undeadpelmen/new-client/internal/display"
 	"github.com/undeadpelmen/new-client/internal/gpio"
+	"github.com/undeadpelmen/new-client/internal/pubsub"
 	"github.com/undeadpelmen/new-client/internal/sensor"
+	"github.com/undeadpelmen/new-client/internal/simulation"
+	"periph.io/x/conn/v3/i2c"
+	"periph.io/x/conn/v3/i2c/i2creg"
 )
 
 type TerrariumController struct {
-	terrarium    *Terrarium
-	relays       *gpio.RelayController
-	sensor       *sensor.DHT22
-	display      *display.OLEDDisplay
-	mockTemp     float32
-	mockHumidity float32
-	mockTempDir  float32
-	mockMu       sync.RWMutex
+	terrarium  *Terrarium
+	relays     gpio.RelayDriver
+	i2cBus     i2c.BusCloser
+	sensorCfg  sensor.Config
+	sensorKey  string
+	sensor     sensor.ClimateSensor
+	sensorMu   sync.Mutex
+	simulation *simulation.Environment
+	oneWire    *sensor.OneWireBus
+	display    *display.OLEDDisplay
+	heaterPID  *PIDController
+	dayNight   dayNightRamp
+	alerts     *alert.Engine
+	events     *pubsub.Broker
+	counters   *controllerCounters
+	pumpTimer  Timer
+	clock      Clock
+	errorCount int
+	cycleMu    sync.Mutex
+	pumpMu     sync.Mutex
 }
 
-func NewTerrariumController(terrarium *Terrarium, relays *gpio.RelayController) *TerrariumController {
-	var dht22 *sensor.DHT22
-	if relays != nil {
-		dht22 = sensor.NewDHT22(relays.GetDHT22Pin(), "GPIO4")
+func NewTerrariumController(terrarium *Terrarium, relays gpio.RelayDriver) *TerrariumController {
+	// The DHT22 and the OLED display only exist on real GPIO hardware.
+	hardware, isHardware := relays.(*gpio.RelayController)
+
+	var sensorCfg sensor.Config
+	var i2cBus i2c.BusCloser
+	if isHardware {
+		sensorCfg.Pin = hardware.GetDHT22Pin()
+
+		// One I2C bus is shared by the OLED display and I2C sensors.
+		bus, err := i2creg.Open("")
+		if err != nil {
+			log.Printf("Failed to open I2C bus: %v", err)
+		} else {
+			i2cBus = bus
+			sensorCfg.Bus = bus
+		}
 	}
 
 	// Initialize OLED display
 	var oledDisplay *display.OLEDDisplay
-	if relays != nil {
-		oled, err := display.NewOLEDDisplay()
-		if err != nil {
+	if i2cBus != nil {
+		oled := display.NewOLEDDisplayOnBus(i2cBus)
+		if err := oled.Init(); err != nil {
 			log.Printf("Failed to initialize OLED display: %v", err)
 			oledDisplay = nil
+		} else if err := oled.Close(); err != nil {
+			log.Printf("Failed to close OLED display: %v", err)
+			oledDisplay = nil
 		} else {
-			if err := oled.Init(); err != nil {
-				log.Printf("Failed to initialize OLED display: %v", err)
-				oledDisplay = nil
-			} else if err := oled.Close(); err != nil {
-				log.Printf("Failed to close OLED display: %v", err)
-				oledDisplay = nil
-			} else {
-				oledDisplay = oled
-				log.Println("OLED display initialized successfully")
-			}
+			oledDisplay = oled
+			log.Println("OLED display initialized successfully")
 		}
 	}
 
-	return &TerrariumController{
-		terrarium:    terrarium,
-		relays:       relays,
-		sensor:       dht22,
-		display:      oledDisplay,
-		mockTemp:     25.0,
-		mockHumidity: 65.0,
-		mockTempDir:  0.1,
+	environment := simulation.NewEnvironment(terrarium.GetSettings().Simulation, relays.States)
+	sensorCfg.Simulated = environment
+
+	tc := &TerrariumController{
+		terrarium:  terrarium,
+		i2cBus:     i2cBus,
+		sensorCfg:  sensorCfg,
+		simulation: environment,
+		display:    oledDisplay,
+		heaterPID:  NewPIDController(),
+		alerts:     alert.NewEngine(),
+		events:     pubsub.NewBroker(),
+		counters:   newControllerCounters(),
+		clock:      realClock{},
 	}
+	tc.relays = &publishingRelays{driver: relays, tc: tc}
+	return tc
 }
 
-func (tc *TerrariumController) readMockSensorData() (temp, humidity float32, err error) {
-	tc.mockMu.Lock()
-	defer tc.mockMu.Unlock()
+// SetClock replaces the wall clock for the control logic and the
+// simulated environment, for running faster than real time.
+func (tc *TerrariumController) SetClock(clock Clock) {
+	tc.clock = clock
+	tc.simulation.SetClock(clock.Now)
+}
 
-	tc.mockTemp += tc.mockTempDir
-	if tc.mockTemp > 28.0 || tc.mockTemp < 22.0 {
-		tc.mockTempDir = -tc.mockTempDir
+// activeSensor returns the driver named in the settings, or the mock
+// driver in simulation mode, opening it again whenever the name or
+// address changes.
+func (tc *TerrariumController) activeSensor() (sensor.ClimateSensor, error) {
+	settings := tc.terrarium.GetSettings()
+	name := settings.SensorDriver
+	if tc.terrarium.Simulated() {
+		name = sensor.DriverMock
 	}
-	tc.mockHumidity += 0.2
-	if tc.mockHumidity > 80.0 {
-		tc.mockHumidity = 50.0
+	key := fmt.Sprintf("%s@0x%02X", name, settings.SensorAddress)
+
+	tc.sensorMu.Lock()
+	defer tc.sensorMu.Unlock()
+
+	if tc.sensor != nil && tc.sensorKey == key {
+		return tc.sensor, nil
 	}
-	log.Printf("Mock data: T=%.1f°C, H=%.1f%%", tc.mockTemp, tc.mockHumidity)
-	return tc.mockTemp, tc.mockHumidity, nil
+	cfg := tc.sensorCfg
+	cfg.Address = settings.SensorAddress
+	climateSensor, err := sensor.Open(name, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("sensor %s not initialized: %v", name, err)
+	}
+	log.Printf("Sensor driver %s opened", name)
+	tc.sensor = climateSensor
+	tc.sensorKey = key
+	return climateSensor, nil
 }
 
-func (tc *TerrariumController) readRealSensorData() (temp, humidity float32, err error) {
-	if tc.sensor == nil {
-		return 0, 0, fmt.Errorf("DHT22 sensor not initialized")
+func (tc *TerrariumController) readClimate() (*sensor.Reading, error) {
+	settings := tc.terrarium.GetSettings()
+
+	tc.simulation.SetParams(settings.Simulation)
+	climateSensor, err := tc.activeSensor()
+	if err != nil {
+		return nil, err
 	}
-	reading, err := tc.sensor.ReadWithRetry(2)
+
+	reading, err := climateSensor.ReadClimate()
+	if err != nil {
+		tc.counters.sensorFailure(SensorSourceClimate, err)
+		log.Printf("%s read error: %v", climateSensor.Name(), err)
+		return nil, err
+	}
+	if reading.Pressure > 0 {
+		log.Printf("%s data: T=%.1f°C, H=%.1f%%, P=%.1fhPa (%s)", climateSensor.Name(),
+			reading.Temperature, reading.Humidity, reading.Pressure, reading.Quality)
+	} else {
+		log.Printf("%s data: T=%.1f°C, H=%.1f%% (%s)", climateSensor.Name(),
+			reading.Temperature, reading.Humidity, reading.Quality)
+	}
+	return reading, nil
+}
+
+func (tc *TerrariumController) ReadSensorData() (temp, humidity float32, err error) {
+	reading, err := tc.readClimate()
 	if err != nil {
-		log.Printf("DHT22 read error: %v", err)
 		return 0, 0, err
 	}
-	log.Printf("DHT22 data: T=%.1f°C, H=%.1f%%",
-		reading.Temperature, reading.Humidity)
+	tc.terrarium.UpdateState(func(s *TerrariumState) {
+		s.SensorQuality = string(reading.Quality)
+		s.CurrentPressure = reading.Pressure
+	})
 	return reading.Temperature, reading.Humidity, nil
 }
 
-func (tc *TerrariumController) ReadSensorData() (temp, humidity float32, err error) {
-	useMock := tc.terrarium.GetSettings().UseMockData
+// EnableOneWire makes every DS18B20 probe on the bus a named temperature
+// channel.
+func (tc *TerrariumController) EnableOneWire(bus *sensor.OneWireBus) {
+	tc.oneWire = bus
+}
+
+func (tc *TerrariumController) readTemperatureChannels(settings *TerrariumSettings) map[string]float32 {
+	if tc.oneWire == nil {
+		return nil
+	}
 
-	if useMock {
-		return tc.readMockSensorData()
+	ids, err := tc.oneWire.Probes()
+	if err != nil {
+		log.Printf("1-Wire enumeration error: %v", err)
+		return nil
 	}
-	return tc.readRealSensorData()
+
+	channels := make(map[string]float32, len(ids))
+	for _, id := range ids {
+		temp, err := tc.oneWire.ReadTemperature(id)
+		if err != nil {
+			tc.counters.sensorFailure(SensorSourceProbe, err)
+			log.Printf("DS18B20 read error: %v", err)
+			continue
+		}
+		name := id
+		if alias, ok := settings.ProbeNames[id]; ok && alias != "" {
+			name = alias
+		}
+		channels[name] = temp
+	}
+	return channels
 }
 
 func (tc *TerrariumController) ShouldLightBeOn() bool {
@@ -107,235 +208,369 @@ func (tc *TerrariumController) ShouldLightBeOn() bool {
 		return false
 	}
 
-	now := time.Now()
-	hour, minute, _ := now.Clock()
-	currentMinutes := hour*60 + minute
-
-	var startHour, startMin int
-	n, err := fmt.Sscanf(settings.LightSchedule.StartTime, "%d:%d", &startHour, &startMin)
-	if err != nil || n != 2 {
-		log.Printf("Failed to parse start time '%s': %v", settings.LightSchedule.StartTime, err)
-		return false
+	now := tc.clock.Now()
+	if settings.LightSchedule.Mode == LightModeSolar {
+		return settings.LightSchedule.Solar.Active(now)
 	}
-	if startHour < 0 || startHour > 23 || startMin < 0 || startMin > 59 {
-		log.Printf("Invalid start time values: hour=%d, min=%d", startHour, startMin)
-		return false
+	if season, ok := settings.Seasons.At(now); ok && season.LightHours > 0 {
+		return seasonalLightActive(season, now)
 	}
-	startMinutes := startHour*60 + startMin
+	return lightWindowsActive(settings.LightSchedule.Windows, now)
+}
 
-	var endHour, endMin int
-	n, err = fmt.Sscanf(settings.LightSchedule.EndTime, "%d:%d", &endHour, &endMin)
-	if err != nil || n != 2 {
-		log.Printf("Failed to parse end time '%s': %v", settings.LightSchedule.EndTime, err)
-		return false
-	}
-	if endHour < 0 || endHour > 23 || endMin < 0 || endMin > 59 {
-		log.Printf("Invalid end time values: hour=%d, min=%d", endHour, endMin)
-		return false
+// effectiveTargets returns the temperature and humidity targets in force
+// at now, and the season they come from when a seasonal profile is active.
+// nightWeight blends from the day targets (0) to the night targets (1).
+func effectiveTargets(settings *TerrariumSettings, now time.Time, nightWeight float32) (float32, float32, *SeasonStatus) {
+	temp, humidity := settings.Targets.Temperature, settings.Targets.Humidity
+	var season *SeasonStatus
+	if status, ok := settings.Seasons.At(now); ok {
+		temp, humidity, season = status.Temperature, status.Humidity, &status
 	}
-	endMinutes := endHour*60 + endMin
 
-	if startMinutes > endMinutes {
-		return currentMinutes >= startMinutes || currentMinutes <= endMinutes
+	if nightWeight > 0 {
+		night := settings.Targets.Night
+		temp += (night.Temperature - settings.Targets.Temperature) * nightWeight
+		humidity += (night.Humidity - settings.Targets.Humidity) * nightWeight
+		humidity = max(0, min(100, humidity))
 	}
+	return temp, humidity, season
+}
 
-	return currentMinutes >= startMinutes && currentMinutes <= endMinutes
+// SolarToday returns today's solar light schedule by the controller clock.
+func (tc *TerrariumController) SolarToday() SolarDay {
+	return tc.terrarium.GetSettings().LightSchedule.Solar.Day(tc.clock.Now())
 }
 
+const maxErrors = 5
+
 func (tc *TerrariumController) ControlLoop(ctx context.Context) {
 	log.Println("Starting terrarium control loop")
 
 	tc.terrarium.UpdateState(func(s *TerrariumState) {
-		s.Uptime = time.Now()
+		s.Uptime = tc.clock.Now()
 		s.SystemMode = "auto"
 	})
 
-	errorCount := 0
-	const maxErrors = 5
-
 	for {
 		select {
 		case <-ctx.Done():
 			log.Println("Stopping control loop")
-			if tc.relays != nil {
-				if err := tc.relays.SetLight(false); err != nil {
-					log.Printf("Error turning off light during shutdown: %v", err)
-				}
-				if err := tc.relays.SetHeater(false); err != nil {
-					log.Printf("Error turning off heater during shutdown: %v", err)
-				}
-				if err := tc.relays.SetPump(false); err != nil {
-					log.Printf("Error turning off pump during shutdown: %v", err)
-				}
+			tc.cancelPumpPulse()
+			if err := tc.relays.SetLight(false); err != nil {
+				log.Printf("Error turning off light during shutdown: %v", err)
+			}
+			if err := tc.relays.SetHeater(false); err != nil {
+				log.Printf("Error turning off heater during shutdown: %v", err)
+			}
+			if err := tc.relays.SetPump(false); err != nil {
+				log.Printf("Error turning off pump during shutdown: %v", err)
 			}
 			return
-
 		default:
-			lightShouldBeOn := tc.ShouldLightBeOn()
+		}
 
-			var currentLightState bool
-			tc.terrarium.UpdateState(func(s *TerrariumState) {
-				currentLightState = s.LightRelay
-			})
+		pause := tc.Step()
 
-			if lightShouldBeOn != currentLightState {
-				if tc.relays != nil {
-					if err := tc.relays.SetLight(lightShouldBeOn); err != nil {
-						log.Printf("Light control error: %v", err)
-						tc.terrarium.UpdateState(func(s *TerrariumState) {
-							s.SystemMode = "error"
-						})
-						errorCount++
-					} else {
-						log.Printf("Lighting: %v", lightShouldBeOn)
-					}
-				}
-
-				tc.terrarium.UpdateState(func(s *TerrariumState) {
-					s.LightRelay = lightShouldBeOn
-				})
-			}
+		select {
+		case <-ctx.Done():
+		case <-time.After(pause):
+		}
+	}
+}
 
-			temp, humidity, err := tc.ReadSensorData()
-			if err != nil {
-				log.Printf("Sensor read error: %v", err)
-				tc.terrarium.UpdateState(func(s *TerrariumState) {
-					s.SensorError = true
-					s.SystemMode = "error"
-				})
-				errorCount++
-
-				tc.terrarium.UpdateState(func(s *TerrariumState) {
-					temp = s.CurrentTemp
-					humidity = s.CurrentHumidity
-				})
-			} else {
-				tc.terrarium.UpdateState(func(s *TerrariumState) {
-					s.SensorError = false
-					s.SystemMode = "auto"
-				})
-				errorCount = 0
-			}
+// Step runs a single control cycle at the controller's clock time and
+// returns the pause before the next cycle.
+func (tc *TerrariumController) Step() time.Duration {
+	tc.cycleMu.Lock()
+	defer tc.cycleMu.Unlock()
 
-			settings := tc.terrarium.GetSettings()
-			targetTemp := settings.Targets.Temperature
+	// Measured on the wall clock: in accelerated simulation the cycle
+	// takes real CPU time, not simulated time.
+	began := time.Now()
+	defer func() { tc.counters.cycle(time.Since(began)) }()
 
-			heaterShouldBeOn := temp < targetTemp
+	tc.expireOverrides()
 
-			var currentHeaterState bool
+	lightScheduled := tc.ShouldLightBeOn()
+	lightShouldBeOn := lightScheduled
+	if state, ok := tc.activeOverride(RelayLight); ok {
+		lightShouldBeOn = state
+	}
+
+	var currentLightState bool
+	tc.terrarium.UpdateState(func(s *TerrariumState) {
+		currentLightState = s.LightRelay
+	})
+
+	if lightShouldBeOn != currentLightState {
+		if err := tc.relays.SetLight(lightShouldBeOn); err != nil {
+			log.Printf("Light control error: %v", err)
 			tc.terrarium.UpdateState(func(s *TerrariumState) {
-				currentHeaterState = s.HeaterRelay
+				s.SystemMode = "error"
 			})
+			tc.errorCount++
+		} else {
+			log.Printf("Lighting: %v", lightShouldBeOn)
+		}
 
-			if heaterShouldBeOn != currentHeaterState {
-				if tc.relays != nil {
-					if err := tc.relays.SetHeater(heaterShouldBeOn); err != nil {
-						log.Printf("Heater control error: %v", err)
-					} else if heaterShouldBeOn {
-						log.Printf("Heater turned on (T=%.1f < %.1f)", temp, targetTemp)
-					} else {
-						log.Printf("Heater turned off")
-					}
-				}
-
-				tc.terrarium.UpdateState(func(s *TerrariumState) {
-					s.HeaterRelay = heaterShouldBeOn
-				})
-			}
+		tc.terrarium.UpdateState(func(s *TerrariumState) {
+			s.LightRelay = lightShouldBeOn
+		})
+	}
 
-			targetHumidity := settings.Targets.Humidity
-			//pumpDuration := settings.PumpSettings.DurationSeconds
-			//pumpMinInterval := settings.PumpSettings.MinInterval
+	temp, humidity, err := tc.ReadSensorData()
+	if err != nil {
+		log.Printf("Sensor read error: %v", err)
+		tc.terrarium.UpdateState(func(s *TerrariumState) {
+			s.SensorError = true
+			s.SystemMode = "error"
+		})
+		tc.errorCount++
+
+		tc.terrarium.UpdateState(func(s *TerrariumState) {
+			temp = s.CurrentTemp
+			humidity = s.CurrentHumidity
+		})
+	} else {
+		tc.terrarium.UpdateState(func(s *TerrariumState) {
+			s.SensorError = false
+			s.SystemMode = "auto"
+		})
+		tc.errorCount = 0
+	}
 
-			//var lastPumpRun time.Time
-			var currentPumpState bool
-			tc.terrarium.UpdateState(func(s *TerrariumState) {
-				//lastPumpRun = s.LastPumpRun
-				currentPumpState = s.PumpRelay
-			})
+	settings := tc.terrarium.GetSettings()
+	night := settings.Targets.Night
+	nightWeight := tc.dayNight.Update(night.isNight(lightScheduled, tc.clock.Now()), night.RampMinutes, tc.clock.Now())
+	targetTemp, targetHumidity, season := effectiveTargets(settings, tc.clock.Now(), nightWeight)
+	tc.terrarium.UpdateState(func(s *TerrariumState) {
+		s.TargetTemp = targetTemp
+		s.TargetHumidity = targetHumidity
+		s.Season = season
+		s.NightWeight = nightWeight
+	})
+
+	channels := tc.readTemperatureChannels(settings)
+	tc.terrarium.UpdateState(func(s *TerrariumState) {
+		s.Channels = channels
+	})
+
+	// The heater zone follows the seasonal and night offset from the
+	// day target.
+	zoneSettings := shiftHeaterZone(settings.Zones, targetTemp-settings.Targets.Temperature)
+	zones, gradient, gradientAlarm := evaluateZones(zoneSettings, temp, channels, settings.MinGradient)
+	if gradientAlarm {
+		log.Printf("Thermal gradient collapsed: %.1f°C < %.1f°C", gradient, settings.MinGradient)
+	}
+	tc.terrarium.UpdateState(func(s *TerrariumState) {
+		s.Zones = zones
+		s.Gradient = gradient
+		s.GradientAlarm = gradientAlarm
+	})
+
+	heaterTemp := temp
+	heaterBand := settings.Targets.TemperatureBand
+	zone, hasHeaterZone := heaterZone(zoneSettings)
+	if hasHeaterZone {
+		// The heater zone switches on below its minimum and off
+		// above its maximum; PID aims for the middle of the range.
+		if zoneTemp, ok := zoneTemperature(zone, temp, channels); ok {
+			heaterTemp = zoneTemp
+		} else {
+			log.Printf("Heater zone %q has no reading, using air temperature", zone.Name)
+		}
+		targetTemp = zone.TargetMin
+		heaterBand.Lower = 0
+		heaterBand.Upper = zone.TargetMax - zone.TargetMin
+	} else if settings.HeaterInput != "" {
+		if channelTemp, ok := channels[settings.HeaterInput]; ok {
+			heaterTemp = channelTemp
+		} else {
+			log.Printf("Heater input channel %q unavailable, using air temperature", settings.HeaterInput)
+		}
+	}
+
+	var currentHeaterState bool
+	var heaterSwitched time.Time
+	tc.terrarium.UpdateState(func(s *TerrariumState) {
+		currentHeaterState = s.HeaterRelay
+		heaterSwitched = s.HeaterSwitched
+	})
+
+	var heaterShouldBeOn bool
+	var heaterCause string
+	if state, ok := tc.activeOverride(RelayHeater); ok {
+		heaterShouldBeOn = state
+		if heaterShouldBeOn != currentHeaterState {
+			heaterCause = CauseManualOverride
+		}
+	} else if settings.HeaterPID.Enabled {
+		setpoint := targetTemp
+		if hasHeaterZone {
+			setpoint += heaterBand.Upper / 2
+		}
+		terms := tc.heaterPID.Update(setpoint, heaterTemp, settings.HeaterPID, tc.clock.Now())
+		heaterShouldBeOn = tc.heaterPID.HeaterOn(settings.HeaterPID.WindowSeconds, tc.clock.Now())
+		if heaterShouldBeOn != currentHeaterState {
+			heaterCause = CausePIDWindow
+		}
+		tc.terrarium.UpdateState(func(s *TerrariumState) {
+			s.HeaterPID = terms
+		})
+	} else {
+		tc.heaterPID.Reset()
+		tc.terrarium.UpdateState(func(s *TerrariumState) {
+			s.HeaterPID = PIDTerms{}
+		})
+		heaterShouldBeOn, heaterCause = bandDecision(heaterTemp, targetTemp,
+			heaterBand, currentHeaterState, heaterSwitched, tc.clock.Now())
+	}
+
+	if heaterShouldBeOn != currentHeaterState {
+		if err := tc.relays.SetHeater(heaterShouldBeOn); err != nil {
+			log.Printf("Heater control error: %v", err)
+		} else if heaterShouldBeOn {
+			log.Printf("Heater turned on (T=%.1f, %s of %.1f)", heaterTemp, heaterCause, targetTemp)
+		} else {
+			log.Printf("Heater turned off (T=%.1f, %s of %.1f)", heaterTemp, heaterCause, targetTemp)
+		}
+
+		tc.terrarium.UpdateState(func(s *TerrariumState) {
+			s.HeaterRelay = heaterShouldBeOn
+			s.HeaterSwitched = tc.clock.Now()
+		})
+	}
 
-			pumpShouldBeOn := humidity < targetHumidity
-
-			if pumpShouldBeOn && !currentPumpState {
-				if tc.relays != nil {
-					if err := tc.relays.SetPump(true); err != nil {
-						log.Printf("Pump turn-on error: %v", err)
-					} else {
-						log.Printf("Pump turned on (H=%.1f < %.1f)",
-							humidity, targetHumidity)
-
-						tc.terrarium.UpdateState(func(s *TerrariumState) {
-							s.LastPumpRun = time.Now()
-						})
-					}
-				}
-
-				tc.terrarium.UpdateState(func(s *TerrariumState) {
-					s.PumpRelay = true
-				})
-			} else if !pumpShouldBeOn && currentPumpState {
-				if tc.relays != nil {
-					if err := tc.relays.SetPump(false); err != nil {
-						log.Printf("Pump turn-off error: %v", err)
-					} else {
-						log.Printf("Pump turned off (H=%.1f > %.1f)", humidity, targetHumidity)
-					}
-				}
-
-				tc.terrarium.UpdateState(func(s *TerrariumState) {
-					s.PumpRelay = false
-				})
+	var pumpShouldBeOn bool
+	var pumpCause string
+	pumpOverride, pumpOverridden := tc.activeOverride(RelayPump)
+	if !pumpOverridden && settings.PumpSettings.DurationSeconds > 0 {
+		pumpShouldBeOn, pumpCause = tc.controlPumpPulse(humidity, targetHumidity, settings, tc.clock.Now())
+	} else {
+		tc.cancelPumpPulse()
+
+		var currentPumpState bool
+		var pumpSwitched time.Time
+		tc.terrarium.UpdateState(func(s *TerrariumState) {
+			currentPumpState = s.PumpRelay
+			pumpSwitched = s.PumpSwitched
+		})
+
+		if pumpOverridden {
+			pumpShouldBeOn = pumpOverride
+			if pumpShouldBeOn != currentPumpState {
+				pumpCause = CauseManualOverride
+			}
+		} else {
+			pumpShouldBeOn, pumpCause = bandDecision(humidity, targetHumidity,
+				settings.Targets.HumidityBand, currentPumpState, pumpSwitched, tc.clock.Now())
+		}
+
+		if pumpShouldBeOn && !currentPumpState {
+			if err := tc.relays.SetPump(true); err != nil {
+				log.Printf("Pump turn-on error: %v", err)
+			} else {
+				log.Printf("Pump turned on (H=%.1f, %s of %.1f)",
+					humidity, pumpCause, targetHumidity)
 			}
 
 			tc.terrarium.UpdateState(func(s *TerrariumState) {
-				s.CurrentTemp = temp
-				s.CurrentHumidity = humidity
-				s.LastSensorRead = time.Now()
-				s.CycleCount++
-
-				if errorCount >= maxErrors {
-					s.SystemMode = "critical"
-					log.Printf("Critical mode! %d consecutive errors", errorCount)
-				}
+				s.PumpRelay = true
+				s.PumpSwitched = tc.clock.Now()
+				s.LastPumpRun = tc.clock.Now()
 			})
-
-			// Update OLED display with sensor data
-			if tc.display != nil {
-				if err := tc.display.DisplaySensorData(temp, humidity); err != nil {
-					log.Printf("Display update error: %v", err)
-				}
+		} else if !pumpShouldBeOn && currentPumpState {
+			if err := tc.relays.SetPump(false); err != nil {
+				log.Printf("Pump turn-off error: %v", err)
+			} else {
+				log.Printf("Pump turned off (H=%.1f, %s of %.1f)", humidity, pumpCause, targetHumidity)
 			}
 
-			tc.terrarium.AddHistoryRecord(HistoricalRecord{
-				Timestamp:   time.Now(),
-				Temperature: temp,
-				Humidity:    humidity,
-				LightOn:     lightShouldBeOn,
-				HeaterOn:    heaterShouldBeOn,
-				PumpOn:      pumpShouldBeOn,
-				SensorError: tc.terrarium.GetState().SensorError,
+			tc.terrarium.UpdateState(func(s *TerrariumState) {
+				s.PumpRelay = false
+				s.PumpSwitched = tc.clock.Now()
 			})
+		}
+	}
 
-			pause := settings.CyclePause
-			if errorCount > 0 {
-				pause = pause * 2
-				log.Printf("Increased pause %d sec due to errors", pause)
-			}
+	tc.terrarium.UpdateState(func(s *TerrariumState) {
+		s.CurrentTemp = temp
+		s.CurrentHumidity = humidity
+		if err == nil {
+			s.LastSensorRead = tc.clock.Now()
+		}
+		s.CycleCount++
 
-			select {
-			case <-ctx.Done():
-				return
-			case <-time.After(time.Duration(pause) * time.Second):
-			}
+		if tc.errorCount >= maxErrors {
+			s.SystemMode = "critical"
+			log.Printf("Critical mode! %d consecutive errors", tc.errorCount)
+		}
+	})
+
+	// Update OLED display with sensor data
+	if tc.display != nil {
+		if err := tc.display.DisplaySensorData(temp, humidity); err != nil {
+			log.Printf("Display update error: %v", err)
 		}
 	}
+
+	record := HistoricalRecord{
+		Timestamp:      tc.clock.Now(),
+		Temperature:    temp,
+		Humidity:       humidity,
+		LightOn:        lightShouldBeOn,
+		HeaterOn:       heaterShouldBeOn,
+		PumpOn:         pumpShouldBeOn,
+		Pressure:       tc.terrarium.GetState().CurrentPressure,
+		Channels:       channels,
+		Zones:          zoneTemperatures(zones),
+		Gradient:       gradient,
+		TargetTemp:     tc.terrarium.GetState().TargetTemp,
+		TargetHumidity: targetHumidity,
+		SensorError:    tc.terrarium.GetState().SensorError,
+		HeaterCause:    heaterCause,
+		PumpCause:      pumpCause,
+	}
+	tc.terrarium.AddHistoryRecord(record)
+	tc.events.Publish(pubsub.TypeHistory, record.Timestamp, record)
+
+	tc.evaluateAlerts(settings)
+	tc.publishState()
+
+	pause := settings.CyclePause
+	if tc.errorCount > 0 {
+		pause = pause * 2
+		log.Printf("Increased pause %d sec due to errors", pause)
+	}
+	return time.Duration(pause) * time.Second
 }
 
-func (tc *TerrariumController) TestSensor() (*sensor.DHT22Reading, error) {
-	if tc.sensor == nil {
-		return nil, fmt.Errorf("DHT22 sensor not initialized")
+func (tc *TerrariumController) TestSensor() (*sensor.Reading, error) {
+	climateSensor, err := tc.activeSensor()
+	if err != nil {
+		return nil, err
 	}
-	return tc.sensor.ReadWithRetry(3)
+	return climateSensor.ReadClimate()
+}
+
+// SensorHeaterPulse runs the built-in heater of the active sensor, for
+// drivers that have one.
+func (tc *TerrariumController) SensorHeaterPulse(duration time.Duration) error {
+	climateSensor, err := tc.activeSensor()
+	if err != nil {
+		return err
+	}
+	pulser, ok := climateSensor.(sensor.HeaterPulser)
+	if !ok {
+		return fmt.Errorf("sensor %s has no built-in heater", climateSensor.Name())
+	}
+	log.Printf("Running %s heater pulse for %v", climateSensor.Name(), duration)
+	return pulser.HeaterPulse(duration)
+}
+
+func (tc *TerrariumController) RelayStates() gpio.RelayStates {
+	return tc.relays.States()
 }
 
 func (tc *TerrariumController) Close() error {
@@ -346,5 +581,11 @@ func (tc *TerrariumController) Close() error {
 		}
 		log.Println("OLED display closed")
 	}
+	if tc.i2cBus != nil {
+		if err := tc.i2cBus.Close(); err != nil {
+			log.Printf("Error closing I2C bus: %v", err)
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,81 @@
+package terrarium
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/undeadpelmen/new-client/internal/sensor"
+)
+
+func TestMockDriverReadsSimulation(t *testing.T) {
+	tests := []struct {
+		name        string
+		driver      string
+		useMockData bool
+	}{
+		{"simulation mode", "dht22", true},
+		{"mock driver selected", sensor.DriverMock, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc, _ := newTestController(t)
+			tc.terrarium.UpdateSettings(func(s *TerrariumSettings) {
+				s.SensorDriver = tt.driver
+				s.UseMockData = tt.useMockData
+			})
+
+			reading, err := tc.readClimate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reading.Quality != sensor.QualitySimulated {
+				t.Errorf("quality %q, want %q", reading.Quality, sensor.QualitySimulated)
+			}
+			if tc.sensor != sensor.ClimateSensor(tc.simulation) {
+				t.Error("reading did not come from the simulated enclosure")
+			}
+		})
+	}
+}
+
+func TestTemperatureChannelsFromOneWire(t *testing.T) {
+	root := t.TempDir()
+	writeProbe := func(id, crc string, milli string) {
+		t.Helper()
+		dir := filepath.Join(root, "w1_bus_master1", id)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		data := "72 01 4b 46 7f ff 0e 10 57 : crc=57 " + crc + "\n72 01 4b 46 7f ff 0e 10 57 t=" + milli + "\n"
+		if err := os.WriteFile(filepath.Join(dir, "w1_slave"), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeProbe("28-00000000000a", "YES", "31500")
+	writeProbe("28-00000000000b", "YES", "24000")
+
+	tc, _ := newTestController(t)
+	tc.EnableOneWire(sensor.NewOneWireBus(root))
+	tc.terrarium.UpdateSettings(func(s *TerrariumSettings) {
+		s.ProbeNames = map[string]string{"28-00000000000a": "basking"}
+	})
+
+	channels := tc.readTemperatureChannels(tc.terrarium.GetSettings())
+	want := map[string]float32{"basking": 31.5, "28-00000000000b": 24}
+	if !maps.Equal(channels, want) {
+		t.Fatalf("channels %v, want %v", channels, want)
+	}
+
+	// A failed checksum drops the channel for this cycle and is counted.
+	writeProbe("28-00000000000b", "NO", "24000")
+	channels = tc.readTemperatureChannels(tc.terrarium.GetSettings())
+	if _, ok := channels["28-00000000000b"]; ok || len(channels) != 1 {
+		t.Fatalf("channels %v, want only basking", channels)
+	}
+	key := SensorFailureKey{Source: SensorSourceProbe, Type: sensor.FailureChecksum}
+	if n := tc.Metrics().SensorFailures[key]; n != 1 {
+		t.Errorf("%d checksum failures counted, want 1", n)
+	}
+}
@@ -1,59 +1,157 @@
 package terrarium
 
 import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
 	"sync"
 	"time"
+
+	"github.com/undeadpelmen/new-client/internal/alert"
+	"github.com/undeadpelmen/new-client/internal/auth"
+	"github.com/undeadpelmen/new-client/internal/gpio"
+	"github.com/undeadpelmen/new-client/internal/simulation"
 )
 
 type TerrariumState struct {
 	mu              sync.RWMutex
-	CurrentTemp     float32   `json:"temperature"`
-	CurrentHumidity float32   `json:"humidity"`
-	LightRelay      bool      `json:"light_on"`
-	HeaterRelay     bool      `json:"heater_on"`
-	PumpRelay       bool      `json:"pump_on"`
-	LastSensorRead  time.Time `json:"last_read"`
-	LastPumpRun     time.Time `json:"last_pump_run"`
-	CycleCount      int64     `json:"cycle_count"`
-	Uptime          time.Time `json:"uptime"`
-	SystemMode      string    `json:"system_mode"`
-	SensorError     bool      `json:"sensor_error"`
+	CurrentTemp     float32                  `json:"temperature"`
+	CurrentHumidity float32                  `json:"humidity"`
+	CurrentPressure float32                  `json:"pressure"`
+	Channels        map[string]float32       `json:"channels"`
+	Zones           []ZoneStatus             `json:"zones"`
+	Gradient        float32                  `json:"gradient"`
+	GradientAlarm   bool                     `json:"gradient_alarm"`
+	LightRelay      bool                     `json:"light_on"`
+	HeaterRelay     bool                     `json:"heater_on"`
+	PumpRelay       bool                     `json:"pump_on"`
+	LastSensorRead  time.Time                `json:"last_read"`
+	LastPumpRun     time.Time                `json:"last_pump_run"`
+	CycleCount      int64                    `json:"cycle_count"`
+	Uptime          time.Time                `json:"uptime"`
+	SystemMode      string                   `json:"system_mode"`
+	SensorError     bool                     `json:"sensor_error"`
+	SensorQuality   string                   `json:"sensor_quality"`
+	HeaterSwitched  time.Time                `json:"heater_switched"`
+	PumpSwitched    time.Time                `json:"pump_switched"`
+	HeaterPID       PIDTerms                 `json:"heater_pid"`
+	Overrides       map[string]RelayOverride `json:"overrides"`
+	TargetTemp      float32                  `json:"target_temperature"`
+	TargetHumidity  float32                  `json:"target_humidity"`
+	Season          *SeasonStatus            `json:"season,omitempty"`
+	NightWeight     float32                  `json:"night_weight"`
+	RelayFault      string                   `json:"relay_fault,omitempty"`
+	// SimulationFallback says why this run uses simulated readings although
+	// use_mock_data is off. It lives only in memory.
+	SimulationFallback string `json:"simulation_fallback,omitempty"`
+}
+
+// ControlBand is the deadband around a target: the actuator switches on
+// below Target-Lower, off above Target+Upper, and holds each state for at
+// least the configured minimum time.
+type ControlBand struct {
+	Lower         float32 `json:"lower"`
+	Upper         float32 `json:"upper"`
+	MinOnSeconds  int     `json:"min_on_seconds"`
+	MinOffSeconds int     `json:"min_off_seconds"`
 }
 
 type TerrariumSettings struct {
-	mu            sync.RWMutex
+	mu sync.RWMutex
+	// StartTime and EndTime are the legacy single window; they are migrated
+	// into Windows on load. Mode selects between Windows and Solar.
 	LightSchedule struct {
-		StartTime string `json:"start_time"`
-		EndTime   string `json:"end_time"`
-		Enabled   bool   `json:"enabled"`
+		StartTime string        `json:"start_time,omitempty"`
+		EndTime   string        `json:"end_time,omitempty"`
+		Mode      string        `json:"mode"`
+		Windows   []LightWindow `json:"windows"`
+		Solar     SolarSchedule `json:"solar"`
+		Enabled   bool          `json:"enabled"`
 	} `json:"light_schedule"`
 	Targets struct {
-		Temperature float32 `json:"temperature"`
-		Humidity    float32 `json:"humidity"`
+		Temperature     float32      `json:"temperature"`
+		Humidity        float32      `json:"humidity"`
+		TemperatureBand ControlBand  `json:"temperature_band"`
+		HumidityBand    ControlBand  `json:"humidity_band"`
+		Night           NightTargets `json:"night"`
 	} `json:"targets"`
-	PumpSettings struct {
-		DurationSeconds int `json:"duration_seconds"`
-		MinInterval     int `json:"min_interval"`
-	} `json:"pump_settings"`
-	CyclePause  int  `json:"cycle_pause"`
-	UseMockData bool `json:"use_mock_data"`
+	// Seasons, when enabled, replaces the target temperature and humidity
+	// and the fixed photoperiod with values that follow the calendar.
+	Seasons SeasonalProfile `json:"seasons"`
+	// DurationSeconds > 0 enables misting pulses of that length;
+	// MinInterval is the cooldown between pulses in minutes. A zero
+	// duration runs the pump continuously within the humidity band.
+	PumpSettings PumpSettings `json:"pump_settings"`
+	HeaterPID    PIDSettings  `json:"heater_pid"`
+	SensorDriver string       `json:"sensor_driver"`
+	// SensorAddress is the I2C address for I2C sensor drivers; zero selects
+	// the driver default.
+	SensorAddress uint16 `json:"sensor_address"`
+	// ProbeNames maps DS18B20 IDs to channel names; unnamed probes use
+	// their ID. HeaterInput selects the channel that drives the heater,
+	// empty for the air sensor.
+	ProbeNames  map[string]string `json:"probe_names"`
+	HeaterInput string            `json:"heater_input"`
+	// Zones describe the thermal gradient. A zone with the heater relay
+	// takes over heater control from Targets.Temperature and HeaterInput.
+	Zones       []ZoneSettings `json:"zones"`
+	MinGradient float32        `json:"min_gradient"`
+	// Alerts holds the alert rules and where their notifications go.
+	Alerts struct {
+		Rules     []alert.Rule           `json:"rules"`
+		Notifiers alert.NotifierSettings `json:"notifiers"`
+	} `json:"alerts"`
+	MQTT MQTTSettings `json:"mqtt"`
+	// Auth controls API access; a settings reset leaves it untouched.
+	Auth auth.Settings `json:"auth"`
+	// Simulation configures the enclosure model used when UseMockData is
+	// set.
+	Simulation simulation.Params `json:"simulation"`
+	// GPIO is read once at startup; changes apply after a restart.
+	GPIO        gpio.PinConfig `json:"gpio"`
+	CyclePause  int            `json:"cycle_pause"`
+	UseMockData bool           `json:"use_mock_data"`
+}
+
+// MQTTSettings configures the MQTT bridge. An empty DiscoveryPrefix turns
+// off Home Assistant discovery. Changes apply after a restart.
+type MQTTSettings struct {
+	Enabled          bool   `json:"enabled"`
+	Broker           string `json:"broker"`
+	ClientID         string `json:"client_id"`
+	Username         string `json:"username,omitempty"`
+	Password         string `json:"password,omitempty"`
+	TopicPrefix      string `json:"topic_prefix"`
+	DiscoveryPrefix  string `json:"discovery_prefix"`
+	PublishHistory   bool   `json:"publish_history"`
+	KeepAliveSeconds int    `json:"keep_alive_seconds"`
 }
 
 type HistoricalRecord struct {
-	Timestamp   time.Time `json:"timestamp"`
-	Temperature float32   `json:"temperature"`
-	Humidity    float32   `json:"humidity"`
-	LightOn     bool      `json:"light_on"`
-	HeaterOn    bool      `json:"heater_on"`
-	PumpOn      bool      `json:"pump_on"`
-	SensorError bool      `json:"sensor_error"`
+	Timestamp      time.Time          `json:"timestamp"`
+	Temperature    float32            `json:"temperature"`
+	Humidity       float32            `json:"humidity"`
+	Pressure       float32            `json:"pressure,omitempty"`
+	Channels       map[string]float32 `json:"channels,omitempty"`
+	Zones          map[string]float32 `json:"zones,omitempty"`
+	Gradient       float32            `json:"gradient,omitempty"`
+	LightOn        bool               `json:"light_on"`
+	HeaterOn       bool               `json:"heater_on"`
+	PumpOn         bool               `json:"pump_on"`
+	SensorError    bool               `json:"sensor_error"`
+	TargetTemp     float32            `json:"target_temperature,omitempty"`
+	TargetHumidity float32            `json:"target_humidity,omitempty"`
+	HeaterCause    string             `json:"heater_cause,omitempty"`
+	PumpCause      string             `json:"pump_cause,omitempty"`
 }
 
 type Terrarium struct {
 	state     *TerrariumState
 	settings  *TerrariumSettings
-	history   []HistoricalRecord
+	history   HistoryStore
 	historyMu sync.RWMutex
+	store     *SettingsStore
 }
 
 func NewTerrarium() *Terrarium {
@@ -63,20 +161,12 @@ func NewTerrarium() *Terrarium {
 	}
 
 	settings := &TerrariumSettings{}
-	settings.LightSchedule.StartTime = "08:00"
-	settings.LightSchedule.EndTime = "20:00"
-	settings.LightSchedule.Enabled = true
-	settings.Targets.Temperature = 26.0
-	settings.Targets.Humidity = 70.0
-	settings.PumpSettings.DurationSeconds = 1000
-	settings.PumpSettings.MinInterval = 3
-	settings.CyclePause = 5
-	settings.UseMockData = false
+	applyDefaultSettings(settings)
 
 	return &Terrarium{
 		state:    state,
 		settings: settings,
-		history:  make([]HistoricalRecord, 0),
+		history:  NewMemoryHistoryStore(),
 	}
 }
 
@@ -92,6 +182,24 @@ func (t *Terrarium) UpdateState(updater func(*TerrariumState)) {
 	t.state.mu.Unlock()
 }
 
+// FallBackToSimulation switches this run to simulated readings without
+// touching the persisted settings, so one bad boot does not stick.
+func (t *Terrarium) FallBackToSimulation(reason string) {
+	t.UpdateState(func(s *TerrariumState) {
+		s.SimulationFallback = reason
+	})
+}
+
+// Simulated reports whether readings come from the simulated enclosure,
+// by setting or by fallback.
+func (t *Terrarium) Simulated() bool {
+	var fallback string
+	t.UpdateState(func(s *TerrariumState) {
+		fallback = s.SimulationFallback
+	})
+	return fallback != "" || t.GetSettings().UseMockData
+}
+
 func (t *Terrarium) GetSettings() *TerrariumSettings {
 	t.settings.mu.RLock()
 	defer t.settings.mu.RUnlock()
@@ -101,52 +209,190 @@ func (t *Terrarium) GetSettings() *TerrariumSettings {
 func (t *Terrarium) UpdateSettings(updater func(*TerrariumSettings)) {
 	t.settings.mu.Lock()
 	updater(t.settings)
+	t.saveSettingsLocked()
 	t.settings.mu.Unlock()
 }
 
-func (t *Terrarium) AddHistoryRecord(record HistoricalRecord) {
-	t.historyMu.Lock()
-	t.history = append(t.history, record)
-	if len(t.history) > 1000 {
-		t.history = t.history[len(t.history)-1000:]
+// LoadSettings attaches a store to the terrarium and replaces the in-memory
+// settings with its contents. A missing or corrupt file keeps the defaults
+// and is rewritten on the next update.
+func (t *Terrarium) LoadSettings(store *SettingsStore) {
+	t.settings.mu.Lock()
+	defer t.settings.mu.Unlock()
+
+	t.store = store
+	if !t.loadSettingsLocked(store) {
+		t.saveSettingsLocked()
+	}
+}
+
+// LoadSettingsSnapshot reads settings from the store without attaching it,
+// so later updates stay in memory.
+func (t *Terrarium) LoadSettingsSnapshot(store *SettingsStore) {
+	t.settings.mu.Lock()
+	defer t.settings.mu.Unlock()
+
+	t.loadSettingsLocked(store)
+}
+
+// loadSettingsLocked reports whether the settings file existed.
+func (t *Terrarium) loadSettingsLocked(store *SettingsStore) bool {
+	data, err := store.Read()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			log.Printf("Settings file %s not found, using defaults", store.Path())
+			return false
+		}
+		log.Printf("Failed to load settings: %v; falling back to defaults", err)
+		return true
+	}
+
+	applyDefaultSettings(t.settings)
+	if err := json.Unmarshal(data, t.settings); err != nil {
+		log.Printf("Failed to apply settings from %s: %v; falling back to defaults", store.Path(), err)
+		applyDefaultSettings(t.settings)
+		return true
+	}
+	if t.settings.LightSchedule.StartTime != "" {
+		log.Println("Migrating single light window to the window list")
+		migrateLightSchedule(t.settings)
+	}
+	if err := ValidateLightWindows(t.settings.LightSchedule.Windows); err != nil {
+		log.Printf("Light schedule in %s is invalid: %v", store.Path(), err)
+	}
+	if err := ValidateLightMode(t.settings.LightSchedule.Mode); err != nil {
+		log.Printf("Light schedule in %s is invalid: %v; using fixed windows", store.Path(), err)
+		t.settings.LightSchedule.Mode = LightModeFixed
+	}
+	if err := t.settings.LightSchedule.Solar.Validate(); err != nil {
+		log.Printf("Solar schedule in %s is invalid: %v", store.Path(), err)
+	}
+	if err := ValidateNightTargets(t.settings.Targets.Night); err != nil {
+		log.Printf("Night targets in %s are invalid: %v", store.Path(), err)
 	}
+	if err := alert.ValidateRules(t.settings.Alerts.Rules); err != nil {
+		log.Printf("Alert rules in %s are invalid: %v", store.Path(), err)
+	}
+	if err := ValidateSeasons(t.settings.Seasons); err != nil {
+		log.Printf("Seasonal profile in %s is invalid: %v", store.Path(), err)
+	}
+	if err := t.settings.Auth.Validate(); err != nil {
+		log.Printf("Authentication settings in %s are invalid: %v", store.Path(), err)
+	}
+	log.Printf("Settings loaded from %s", store.Path())
+	return true
+}
+
+func (t *Terrarium) saveSettingsLocked() {
+	if t.store == nil {
+		return
+	}
+	if err := t.store.Write(t.settings); err != nil {
+		log.Printf("Failed to save settings: %v", err)
+	}
+}
+
+func (t *Terrarium) SetHistoryStore(store HistoryStore) {
+	t.historyMu.Lock()
+	t.history = store
 	t.historyMu.Unlock()
 }
 
-func (t *Terrarium) GetHistory(limit int) []HistoricalRecord {
+func (t *Terrarium) AddHistoryRecord(record HistoricalRecord) {
 	t.historyMu.RLock()
 	defer t.historyMu.RUnlock()
-
-	if limit <= 0 || limit > len(t.history) {
-		limit = len(t.history)
+	if err := t.history.Append(record); err != nil {
+		log.Printf("Failed to store history record: %v", err)
 	}
+}
 
-	recentHistory := t.history
-	if len(t.history) > limit {
-		recentHistory = t.history[len(t.history)-limit:]
-	}
+func (t *Terrarium) GetHistory(limit int) []HistoricalRecord {
+	t.historyMu.RLock()
+	defer t.historyMu.RUnlock()
 
-	result := make([]HistoricalRecord, len(recentHistory))
-	copy(result, recentHistory)
-	return result
+	history, err := t.history.Recent(limit)
+	if err != nil {
+		log.Printf("Failed to read history: %v", err)
+		return []HistoricalRecord{}
+	}
+	return history
 }
 
 func (t *Terrarium) GetHistoryCount() int {
 	t.historyMu.RLock()
 	defer t.historyMu.RUnlock()
-	return len(t.history)
+	return t.history.Count()
+}
+
+func (t *Terrarium) Close() error {
+	t.historyMu.Lock()
+	defer t.historyMu.Unlock()
+	return t.history.Close()
 }
 
 func (t *Terrarium) ResetSettings() {
 	t.UpdateSettings(func(s *TerrariumSettings) {
-		s.LightSchedule.StartTime = "08:00"
-		s.LightSchedule.EndTime = "20:00"
-		s.LightSchedule.Enabled = true
-		s.Targets.Temperature = 26.0
-		s.Targets.Humidity = 70.0
-		s.PumpSettings.DurationSeconds = 1000
-		s.PumpSettings.MinInterval = 3
-		s.CyclePause = 5
-		s.UseMockData = false
+		// Keep credentials so a reset neither locks admins out nor opens
+		// the API to everyone.
+		access := s.Auth
+		applyDefaultSettings(s)
+		s.Auth = access
 	})
 }
+
+func applyDefaultSettings(s *TerrariumSettings) {
+	s.LightSchedule.StartTime = ""
+	s.LightSchedule.EndTime = ""
+	s.LightSchedule.Mode = LightModeFixed
+	s.LightSchedule.Windows = []LightWindow{{Start: "08:00", End: "20:00"}}
+	s.LightSchedule.Solar = SolarSchedule{}
+	s.LightSchedule.Enabled = true
+	s.Targets.Temperature = 26.0
+	s.Targets.Humidity = 70.0
+	s.Targets.TemperatureBand = ControlBand{Lower: 0.5, Upper: 0.5, MinOnSeconds: 30, MinOffSeconds: 30}
+	s.Targets.HumidityBand = ControlBand{Lower: 3.0, Upper: 3.0, MinOnSeconds: 10, MinOffSeconds: 30}
+	s.Targets.Night = NightTargets{
+		Enabled:     false,
+		Temperature: 22.0,
+		Humidity:    80.0,
+		Source:      NightSourceLight,
+		RampMinutes: 60,
+	}
+	s.Seasons = SeasonalProfile{LightCenter: "13:00", Keyframes: []SeasonKeyframe{}}
+	s.HeaterPID = PIDSettings{
+		Enabled:       false,
+		Kp:            0.5,
+		Ki:            0.005,
+		Kd:            0,
+		WindowSeconds: 60,
+		IntegralMin:   0,
+		IntegralMax:   0.5,
+	}
+	s.PumpSettings.DurationSeconds = 10
+	s.PumpSettings.MinInterval = 3
+	s.SensorDriver = "dht22"
+	s.ProbeNames = map[string]string{}
+	s.HeaterInput = ""
+	s.Zones = []ZoneSettings{}
+	s.MinGradient = 3.0
+	s.Alerts.Rules = alert.DefaultRules()
+	s.Alerts.Notifiers = alert.NotifierSettings{Webhooks: []alert.WebhookConfig{}}
+	s.MQTT = MQTTSettings{
+		Broker:           "tcp://localhost:1883",
+		ClientID:         "terrarium",
+		TopicPrefix:      "terrarium",
+		DiscoveryPrefix:  "homeassistant",
+		PublishHistory:   true,
+		KeepAliveSeconds: 60,
+	}
+	s.Auth = auth.Settings{
+		Users:          []auth.User{},
+		Tokens:         []auth.Token{},
+		AllowedOrigins: []string{},
+		SessionHours:   24,
+	}
+	s.Simulation = simulation.DefaultParams()
+	s.GPIO = gpio.DefaultPinConfig()
+	s.CyclePause = 5
+	s.UseMockData = false
+}
@@ -0,0 +1,174 @@
+package terrarium
+
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	ZoneRoleHot     = "hot"
+	ZoneRoleCold    = "cold"
+	ZoneRoleBasking = "basking"
+
+	// ZoneSensorAir selects the main climate sensor as a zone input;
+	// any other value names a temperature channel.
+	ZoneSensorAir = "air"
+
+	ZoneRelayHeater = "heater"
+
+	ZoneStatusOK     = "ok"
+	ZoneStatusLow    = "low"
+	ZoneStatusHigh   = "high"
+	ZoneStatusNoData = "no_data"
+)
+
+type ZoneSettings struct {
+	Name      string  `json:"name"`
+	Role      string  `json:"role"`
+	Sensor    string  `json:"sensor"`
+	TargetMin float32 `json:"target_min"`
+	TargetMax float32 `json:"target_max"`
+	Relay     string  `json:"relay"`
+}
+
+type ZoneStatus struct {
+	Name        string  `json:"name"`
+	Role        string  `json:"role"`
+	Temperature float32 `json:"temperature"`
+	TargetMin   float32 `json:"target_min"`
+	TargetMax   float32 `json:"target_max"`
+	Relay       string  `json:"relay,omitempty"`
+	Status      string  `json:"status"`
+}
+
+func ValidateZones(zones []ZoneSettings) error {
+	names := make(map[string]bool)
+	heaterZones := 0
+	for i, zone := range zones {
+		if zone.Name == "" {
+			return fmt.Errorf("zone %d has no name", i)
+		}
+		if names[zone.Name] {
+			return fmt.Errorf("duplicate zone name %q", zone.Name)
+		}
+		names[zone.Name] = true
+
+		switch zone.Role {
+		case "", ZoneRoleHot, ZoneRoleCold, ZoneRoleBasking:
+		default:
+			return fmt.Errorf("zone %q has unknown role %q", zone.Name, zone.Role)
+		}
+		if zone.Sensor == "" {
+			return fmt.Errorf("zone %q has no sensor", zone.Name)
+		}
+		if zone.TargetMin > zone.TargetMax {
+			return fmt.Errorf("zone %q target_min is above target_max", zone.Name)
+		}
+
+		switch zone.Relay {
+		case "":
+		case ZoneRelayHeater:
+			heaterZones++
+		default:
+			return fmt.Errorf("zone %q has unknown relay %q", zone.Name, zone.Relay)
+		}
+	}
+	if heaterZones > 1 {
+		return fmt.Errorf("only one zone can control the heater")
+	}
+	return nil
+}
+
+// evaluateZones reads every zone from the air sensor or its channel and
+// computes the hot minus cold gradient. The gradient alarm is raised when
+// both sides are known and the gradient is below minGradient.
+func evaluateZones(zones []ZoneSettings, air float32, channels map[string]float32, minGradient float32) ([]ZoneStatus, float32, bool) {
+	statuses := make([]ZoneStatus, 0, len(zones))
+	var hot, cold float32
+	var haveHot, haveCold bool
+
+	for _, zone := range zones {
+		status := ZoneStatus{
+			Name:      zone.Name,
+			Role:      zone.Role,
+			TargetMin: zone.TargetMin,
+			TargetMax: zone.TargetMax,
+			Relay:     zone.Relay,
+		}
+
+		temp, ok := zoneTemperature(zone, air, channels)
+		switch {
+		case !ok:
+			status.Status = ZoneStatusNoData
+		case temp < zone.TargetMin:
+			status.Status = ZoneStatusLow
+		case temp > zone.TargetMax:
+			status.Status = ZoneStatusHigh
+		default:
+			status.Status = ZoneStatusOK
+		}
+		if ok {
+			status.Temperature = temp
+			if zone.Role == ZoneRoleHot && !haveHot {
+				hot, haveHot = temp, true
+			}
+			if zone.Role == ZoneRoleCold && !haveCold {
+				cold, haveCold = temp, true
+			}
+		}
+		statuses = append(statuses, status)
+	}
+
+	if !haveHot || !haveCold {
+		return statuses, 0, false
+	}
+	gradient := hot - cold
+	return statuses, gradient, gradient < minGradient
+}
+
+func zoneTemperature(zone ZoneSettings, air float32, channels map[string]float32) (float32, bool) {
+	if zone.Sensor == ZoneSensorAir {
+		return air, true
+	}
+	temp, ok := channels[zone.Sensor]
+	return temp, ok
+}
+
+// shiftHeaterZone returns zones with the heater zone's range moved by
+// offset, so seasonal and night targets move it like the air target. The
+// settings are never modified.
+func shiftHeaterZone(zones []ZoneSettings, offset float32) []ZoneSettings {
+	if offset == 0 {
+		return zones
+	}
+	shifted := slices.Clone(zones)
+	for i := range shifted {
+		if shifted[i].Relay == ZoneRelayHeater {
+			shifted[i].TargetMin += offset
+			shifted[i].TargetMax += offset
+		}
+	}
+	return shifted
+}
+
+func heaterZone(zones []ZoneSettings) (ZoneSettings, bool) {
+	for _, zone := range zones {
+		if zone.Relay == ZoneRelayHeater {
+			return zone, true
+		}
+	}
+	return ZoneSettings{}, false
+}
+
+func zoneTemperatures(statuses []ZoneStatus) map[string]float32 {
+	if len(statuses) == 0 {
+		return nil
+	}
+	temps := make(map[string]float32, len(statuses))
+	for _, status := range statuses {
+		if status.Status != ZoneStatusNoData {
+			temps[status.Name] = status.Temperature
+		}
+	}
+	return temps
+}
@@ -0,0 +1,37 @@
+package terrarium
+
+import "testing"
+
+func TestHeaterZoneFollowsNightTargets(t *testing.T) {
+	tc, _ := newTestController(t)
+	tc.terrarium.UpdateSettings(func(s *TerrariumSettings) {
+		s.Targets.Temperature = 26
+		s.Targets.Night = NightTargets{
+			Enabled:     true,
+			Temperature: 21,
+			Humidity:    s.Targets.Humidity,
+			Source:      NightSourceClock,
+			Start:       "00:00",
+			End:         "23:59",
+		}
+		s.Zones = []ZoneSettings{
+			{Name: "basking", Role: ZoneRoleHot, Sensor: ZoneSensorAir, TargetMin: 30, TargetMax: 34, Relay: ZoneRelayHeater},
+			{Name: "cool", Role: ZoneRoleCold, Sensor: ZoneSensorAir, TargetMin: 22, TargetMax: 26},
+		}
+	})
+	tc.Step()
+
+	zones := tc.terrarium.GetState().Zones
+	if len(zones) != 2 {
+		t.Fatalf("got %d zones", len(zones))
+	}
+	if zones[0].TargetMin != 25 || zones[0].TargetMax != 29 {
+		t.Errorf("heater zone range %.1f-%.1f at night, want 25.0-29.0", zones[0].TargetMin, zones[0].TargetMax)
+	}
+	if zones[1].TargetMin != 22 || zones[1].TargetMax != 26 {
+		t.Errorf("zone without the heater moved to %.1f-%.1f", zones[1].TargetMin, zones[1].TargetMax)
+	}
+	if settings := tc.terrarium.GetSettings(); settings.Zones[0].TargetMin != 30 {
+		t.Errorf("settings were modified: target_min %.1f", settings.Zones[0].TargetMin)
+	}
+}
@@ -0,0 +1,160 @@
+// Package tlscert provides the HTTPS certificate: one supplied by the user,
+// or a self-signed one generated on first start and kept on disk.
+package tlscert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/undeadpelmen/new-client/internal/atomicfile"
+)
+
+const (
+	CertFile = "cert.pem"
+	KeyFile  = "key.pem"
+
+	validity = 5 * 365 * 24 * time.Hour
+	// renewBefore regenerates a self-signed certificate this long before
+	// it expires.
+	renewBefore = 30 * 24 * time.Hour
+)
+
+// Load reads a user-provided certificate and key.
+func Load(certPath, keyPath string) (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to load %s and %s: %v", certPath, keyPath, err)
+	}
+	return cert, nil
+}
+
+// LoadOrCreate returns the self-signed certificate in dir, generating it
+// for hosts when it is missing, unreadable or about to expire. A key that
+// does not match its certificate, e.g. after a crash between the two
+// writes, is replaced the same way. Certificates are not regenerated when
+// the hosts change; delete dir to pick up new names.
+func LoadOrCreate(dir string, hosts []string) (tls.Certificate, error) {
+	certPath, keyPath := filepath.Join(dir, CertFile), filepath.Join(dir, KeyFile)
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	switch {
+	case err == nil && time.Until(cert.Leaf.NotAfter) > renewBefore:
+		return cert, nil
+	case err == nil:
+		log.Printf("Self-signed certificate in %s expires %s, generating a new one",
+			dir, cert.Leaf.NotAfter.Format(time.DateOnly))
+	case !errors.Is(err, os.ErrNotExist):
+		log.Printf("Self-signed certificate in %s is unusable (%v), generating a new one", dir, err)
+	}
+
+	certPEM, keyPEM, err := generate(hosts, time.Now())
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+	if err := atomicfile.Write(keyPath, keyPEM, 0o600); err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := atomicfile.Write(certPath, certPEM, 0o644); err != nil {
+		return tls.Certificate{}, err
+	}
+
+	cert, err = tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	log.Printf("Generated self-signed certificate %s for %s", certPath, strings.Join(hosts, ", "))
+	return cert, nil
+}
+
+func generate(hosts []string, now time.Time) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate serial number: %v", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Terrarium controller"}, CommonName: hosts[0]},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode key: %v", err)
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// DefaultHosts lists the names a browser may use to reach this machine:
+// the hostname, localhost and every interface address, VPN tunnels
+// included.
+func DefaultHosts() []string {
+	hosts := []string{}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		hosts = append(hosts, hostname)
+	}
+	hosts = append(hosts, "localhost")
+
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLinkLocalUnicast() {
+				hosts = append(hosts, ipNet.IP.String())
+			}
+		}
+	}
+	return hosts
+}
+
+// Fingerprint is the SHA-256 of the leaf certificate, for checking the
+// certificate a browser shows before trusting it.
+func Fingerprint(cert tls.Certificate) string {
+	if len(cert.Certificate) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(cert.Certificate[0])
+	pairs := make([]string, len(sum))
+	for i, b := range sum {
+		pairs[i] = strings.ToUpper(hex.EncodeToString([]byte{b}))
+	}
+	return strings.Join(pairs, ":")
+}
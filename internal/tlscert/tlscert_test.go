@@ -0,0 +1,98 @@
+package tlscert
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestLoadOrCreateKeepsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	first, err := LoadOrCreate(dir, []string{"localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := LoadOrCreate(dir, []string{"localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Fingerprint(first) != Fingerprint(second) {
+		t.Fatal("certificate regenerated although it was valid")
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Fatalf("expected only %s and %s, found %d files", CertFile, KeyFile, len(entries))
+	}
+}
+
+func TestLoadOrCreateReplacesBrokenPair(t *testing.T) {
+	tests := []struct {
+		name      string
+		breakPair func(t *testing.T, dir string)
+	}{
+		{"key from another pair", func(t *testing.T, dir string) {
+			_, keyPEM, err := generate([]string{"localhost"}, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeFile(t, filepath.Join(dir, KeyFile), keyPEM)
+		}},
+		{"truncated certificate", func(t *testing.T, dir string) {
+			writeFile(t, filepath.Join(dir, CertFile), []byte("-----BEGIN CERT"))
+		}},
+		{"missing key", func(t *testing.T, dir string) {
+			if err := os.Remove(filepath.Join(dir, KeyFile)); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"expiring soon", func(t *testing.T, dir string) {
+			certPEM, keyPEM, err := generate([]string{"localhost"}, time.Now().Add(renewBefore-validity))
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeFile(t, filepath.Join(dir, CertFile), certPEM)
+			writeFile(t, filepath.Join(dir, KeyFile), keyPEM)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			original, err := LoadOrCreate(dir, []string{"localhost"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.breakPair(t, dir)
+
+			replaced, err := LoadOrCreate(dir, []string{"localhost"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if Fingerprint(replaced) == Fingerprint(original) {
+				t.Error("certificate was not regenerated")
+			}
+			stored, err := Load(filepath.Join(dir, CertFile), filepath.Join(dir, KeyFile))
+			if err != nil {
+				t.Fatalf("stored pair still unusable: %v", err)
+			}
+			if Fingerprint(stored) != Fingerprint(replaced) {
+				t.Error("stored pair differs from the one returned")
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,107 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/undeadpelmen/new-client/internal/alert"
+)
+
+func (api *WebAPI) getAlerts(c *gin.Context) {
+	statuses := api.controller.Alerts()
+
+	firing := 0
+	for _, status := range statuses {
+		if status.State == alert.StateFiring {
+			firing++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   statuses,
+		"meta": gin.H{
+			"rules":  len(statuses),
+			"firing": firing,
+		},
+	})
+}
+
+func (api *WebAPI) acknowledgeAlert(c *gin.Context) {
+	id := c.Param("id")
+	if err := api.controller.AcknowledgeAlert(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Acknowledge failed: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": fmt.Sprintf("Alert %s acknowledged", id),
+	})
+}
+
+func (api *WebAPI) snoozeAlert(c *gin.Context) {
+	id := c.Param("id")
+
+	var request struct {
+		DurationMinutes float64 `json:"duration_minutes"`
+	}
+	if err := c.BindJSON(&request); err != nil || request.DurationMinutes <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Expected {\"duration_minutes\": > 0}",
+		})
+		return
+	}
+
+	duration := time.Duration(request.DurationMinutes * float64(time.Minute))
+	if err := api.controller.SnoozeAlert(id, duration); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Snooze failed: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": fmt.Sprintf("Alert %s snoozed for %v", id, duration),
+	})
+}
+
+func (api *WebAPI) unsnoozeAlert(c *gin.Context) {
+	id := c.Param("id")
+	if err := api.controller.SnoozeAlert(id, 0); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Unsnooze failed: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": fmt.Sprintf("Alert %s no longer snoozed", id),
+	})
+}
+
+func (api *WebAPI) testNotifiers(c *gin.Context) {
+	results := api.controller.TestNotifiers(c.Request.Context())
+	if len(results) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "No notifiers configured",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   results,
+	})
+}
@@ -1,58 +1,131 @@
 package web
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"slices"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/undeadpelmen/new-client/internal/alert"
+	"github.com/undeadpelmen/new-client/internal/auth"
+	"github.com/undeadpelmen/new-client/internal/gpio"
+	"github.com/undeadpelmen/new-client/internal/mqttbridge"
+	"github.com/undeadpelmen/new-client/internal/sensor"
+	"github.com/undeadpelmen/new-client/internal/simulation"
 	"github.com/undeadpelmen/new-client/internal/terrarium"
 )
 
 type WebAPI struct {
 	terrarium  *terrarium.Terrarium
 	controller *terrarium.TerrariumController
+	sessions   *auth.Sessions
+	done       chan struct{}
+	closeOnce  sync.Once
 }
 
 func NewWebAPI(terrarium *terrarium.Terrarium, controller *terrarium.TerrariumController) *WebAPI {
 	return &WebAPI{
 		terrarium:  terrarium,
 		controller: controller,
+		sessions:   auth.NewSessions(),
+		done:       make(chan struct{}),
 	}
 }
 
 func (api *WebAPI) getState(c *gin.Context) {
-	state := api.terrarium.GetState()
-
 	c.JSON(http.StatusOK, gin.H{
 		"status": "success",
-		"data": gin.H{
-			"timestamp": time.Now().Format(time.RFC3339),
-			"sensors": gin.H{
-				"temperature":  state.CurrentTemp,
-				"humidity":     state.CurrentHumidity,
-				"last_read":    state.LastSensorRead.Format(time.RFC3339),
-				"sensor_error": state.SensorError,
-			},
-			"relays": gin.H{
-				"light":         state.LightRelay,
-				"heater":        state.HeaterRelay,
-				"pump":          state.PumpRelay,
-				"last_pump_run": state.LastPumpRun.Format(time.RFC3339),
-			},
-			"system": gin.H{
-				"cycle_count": state.CycleCount,
-				"uptime":      int(time.Since(state.Uptime).Seconds()),
-				"mode":        state.SystemMode,
-			},
-		},
+		"data":   api.stateData(),
 	})
 }
 
+func (api *WebAPI) stateData() gin.H {
+	state := api.terrarium.GetState()
+	actual := api.controller.RelayStates()
+
+	settings := api.terrarium.GetSettings()
+	lightSchedule := gin.H{
+		"mode":    settings.LightSchedule.Mode,
+		"enabled": settings.LightSchedule.Enabled,
+	}
+	if settings.LightSchedule.Mode == terrarium.LightModeSolar {
+		lightSchedule["today"] = api.controller.SolarToday()
+	}
+
+	return gin.H{
+		"timestamp": time.Now().Format(time.RFC3339),
+		"sensors": gin.H{
+			"temperature":  state.CurrentTemp,
+			"humidity":     state.CurrentHumidity,
+			"pressure":     state.CurrentPressure,
+			"channels":     state.Channels,
+			"last_read":    state.LastSensorRead.Format(time.RFC3339),
+			"sensor_error": state.SensorError,
+			"quality":      state.SensorQuality,
+			"driver":       settings.SensorDriver,
+		},
+		"relays": gin.H{
+			"light":         state.LightRelay,
+			"heater":        state.HeaterRelay,
+			"pump":          state.PumpRelay,
+			"last_pump_run": state.LastPumpRun.Format(time.RFC3339),
+			"heater_switch": state.HeaterSwitched.Format(time.RFC3339),
+			"pump_switch":   state.PumpSwitched.Format(time.RFC3339),
+			"actual":        actual,
+			"overrides":     state.Overrides,
+		},
+		"light_schedule": lightSchedule,
+		"targets": gin.H{
+			"temperature":  state.TargetTemp,
+			"humidity":     state.TargetHumidity,
+			"season":       state.Season,
+			"night_weight": state.NightWeight,
+		},
+		"zones": gin.H{
+			"zones":          state.Zones,
+			"gradient":       state.Gradient,
+			"gradient_alarm": state.GradientAlarm,
+		},
+		"heater_pid": gin.H{
+			"output": state.HeaterPID.Output,
+			"p":      state.HeaterPID.P,
+			"i":      state.HeaterPID.I,
+			"d":      state.HeaterPID.D,
+		},
+		"system": gin.H{
+			"cycle_count": state.CycleCount,
+			"uptime":      int(time.Since(state.Uptime).Seconds()),
+			"mode":        state.SystemMode,
+			"simulated":   api.terrarium.Simulated(),
+			"fallback":    state.SimulationFallback,
+		},
+	}
+}
+
+const (
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 10000
+)
+
 func (api *WebAPI) getHistory(c *gin.Context) {
-	limit := 100
+	limit := defaultHistoryLimit
 	if limitStr := c.Query("limit"); limitStr != "" {
-		fmt.Sscanf(limitStr, "%d", &limit)
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "limit must be a positive number",
+			})
+			return
+		}
+		// A disk-backed history can hold far more than fits in one
+		// response.
+		limit = min(n, maxHistoryLimit)
 	}
 
 	history := api.terrarium.GetHistory(limit)
@@ -69,13 +142,37 @@ func (api *WebAPI) getHistory(c *gin.Context) {
 }
 
 func (api *WebAPI) getSettings(c *gin.Context) {
-	settings := api.terrarium.GetSettings()
+	settings, err := api.redactedSettings()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Failed to encode settings: %v", err),
+		})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"status": "success",
 		"data":   settings,
 	})
 }
 
+// redactedSettings copies the settings without credentials. Secrets are
+// omitted rather than masked, so sending a section back keeps them.
+func (api *WebAPI) redactedSettings() (*terrarium.TerrariumSettings, error) {
+	encoded, err := json.Marshal(api.terrarium.GetSettings())
+	if err != nil {
+		return nil, err
+	}
+	var settings terrarium.TerrariumSettings
+	if err := json.Unmarshal(encoded, &settings); err != nil {
+		return nil, err
+	}
+	settings.Auth = settings.Auth.Redacted()
+	settings.Alerts.Notifiers.SMTP.Password = ""
+	settings.MQTT.Password = ""
+	return &settings, nil
+}
+
 func (api *WebAPI) updateSettings(c *gin.Context) {
 	var updateData map[string]interface{}
 
@@ -87,13 +184,387 @@ func (api *WebAPI) updateSettings(c *gin.Context) {
 		return
 	}
 
+	if driver, ok := updateData["sensor_driver"].(string); ok && !slices.Contains(sensor.Drivers(), driver) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Unknown sensor driver %q (available: %v)", driver, sensor.Drivers()),
+		})
+		return
+	}
+
+	if raw, ok := updateData["sensor_address"]; ok {
+		// Zero selects the driver default; anything else must be a 7-bit
+		// I2C address.
+		address, ok := raw.(float64)
+		if !ok || address != math.Trunc(address) || address < 0 || address >= 0x80 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Invalid sensor_address %v: must be an integer from 0 to 0x7f", raw),
+			})
+			return
+		}
+	}
+
+	var lightWindows []terrarium.LightWindow
+	var solar *terrarium.SolarSchedule
+	if lightSchedule, ok := updateData["light_schedule"].(map[string]interface{}); ok {
+		if mode, ok := lightSchedule["mode"].(string); ok {
+			if err := terrarium.ValidateLightMode(mode); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status":  "error",
+					"message": fmt.Sprintf("Invalid light schedule: %v", err),
+				})
+				return
+			}
+		}
+		if rawSolar, ok := lightSchedule["solar"].(map[string]interface{}); ok {
+			encoded, _ := json.Marshal(rawSolar)
+			merged := api.terrarium.GetSettings().LightSchedule.Solar
+			if err := json.Unmarshal(encoded, &merged); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status":  "error",
+					"message": "Invalid solar schedule format",
+				})
+				return
+			}
+			if err := merged.Validate(); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status":  "error",
+					"message": fmt.Sprintf("Invalid solar schedule: %v", err),
+				})
+				return
+			}
+			solar = &merged
+		}
+		if rawWindows, ok := lightSchedule["windows"]; ok {
+			encoded, _ := json.Marshal(rawWindows)
+			if err := json.Unmarshal(encoded, &lightWindows); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status":  "error",
+					"message": "Invalid light windows format",
+				})
+				return
+			}
+		} else {
+			// Legacy clients update a single daily window.
+			start, hasStart := lightSchedule["start_time"].(string)
+			end, hasEnd := lightSchedule["end_time"].(string)
+			if hasStart || hasEnd {
+				window := terrarium.LightWindow{Start: "08:00", End: "20:00"}
+				if current := api.terrarium.GetSettings().LightSchedule.Windows; len(current) > 0 {
+					window = current[0]
+				}
+				if hasStart {
+					window.Start = start
+				}
+				if hasEnd {
+					window.End = end
+				}
+				window.Days = nil
+				lightWindows = []terrarium.LightWindow{window}
+			}
+		}
+		if lightWindows != nil {
+			if err := terrarium.ValidateLightWindows(lightWindows); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status":  "error",
+					"message": fmt.Sprintf("Invalid light schedule: %v", err),
+				})
+				return
+			}
+		}
+	}
+
+	var night *terrarium.NightTargets
+	var temperatureBand, humidityBand *terrarium.ControlBand
+	if targets, ok := updateData["targets"].(map[string]interface{}); ok {
+		current := api.terrarium.GetSettings().Targets
+		if rawBand, ok := targets["temperature_band"].(map[string]interface{}); ok {
+			merged, err := mergeControlBand(rawBand, current.TemperatureBand)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status":  "error",
+					"message": fmt.Sprintf("Invalid temperature band: %v", err),
+				})
+				return
+			}
+			temperatureBand = &merged
+		}
+		if rawBand, ok := targets["humidity_band"].(map[string]interface{}); ok {
+			merged, err := mergeControlBand(rawBand, current.HumidityBand)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status":  "error",
+					"message": fmt.Sprintf("Invalid humidity band: %v", err),
+				})
+				return
+			}
+			humidityBand = &merged
+		}
+		if rawNight, ok := targets["night"].(map[string]interface{}); ok {
+			encoded, _ := json.Marshal(rawNight)
+			merged := api.terrarium.GetSettings().Targets.Night
+			if err := json.Unmarshal(encoded, &merged); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status":  "error",
+					"message": "Invalid night targets format",
+				})
+				return
+			}
+			if err := terrarium.ValidateNightTargets(merged); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status":  "error",
+					"message": fmt.Sprintf("Invalid night targets: %v", err),
+				})
+				return
+			}
+			night = &merged
+		}
+	}
+
+	var pumpSettings *terrarium.PumpSettings
+	if rawPump, ok := updateData["pump_settings"].(map[string]interface{}); ok {
+		encoded, _ := json.Marshal(rawPump)
+		merged := api.terrarium.GetSettings().PumpSettings
+		if err := json.Unmarshal(encoded, &merged); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Invalid pump_settings format",
+			})
+			return
+		}
+		if err := merged.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Invalid pump settings: %v", err),
+			})
+			return
+		}
+		pumpSettings = &merged
+	}
+
+	var heaterPID *terrarium.PIDSettings
+	if rawPID, ok := updateData["heater_pid"].(map[string]interface{}); ok {
+		encoded, _ := json.Marshal(rawPID)
+		merged := api.terrarium.GetSettings().HeaterPID
+		if err := json.Unmarshal(encoded, &merged); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Invalid heater_pid format",
+			})
+			return
+		}
+		if err := merged.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Invalid heater PID settings: %v", err),
+			})
+			return
+		}
+		heaterPID = &merged
+	}
+
+	var alertRules []alert.Rule
+	var notifiers *alert.NotifierSettings
+	if alerts, ok := updateData["alerts"].(map[string]interface{}); ok {
+		if rawRules, ok := alerts["rules"]; ok {
+			encoded, _ := json.Marshal(rawRules)
+			if err := json.Unmarshal(encoded, &alertRules); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status":  "error",
+					"message": "Invalid alert rules format",
+				})
+				return
+			}
+			if err := alert.ValidateRules(alertRules); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status":  "error",
+					"message": fmt.Sprintf("Invalid alert rules: %v", err),
+				})
+				return
+			}
+		}
+		if rawNotifiers, ok := alerts["notifiers"].(map[string]interface{}); ok {
+			encoded, _ := json.Marshal(rawNotifiers)
+			merged := api.terrarium.GetSettings().Alerts.Notifiers
+			// Sent lists replace the live ones in fresh slices; json would
+			// otherwise decode into the live backing arrays.
+			if _, ok := rawNotifiers["webhooks"]; ok {
+				merged.Webhooks = nil
+			}
+			if smtp, ok := rawNotifiers["smtp"].(map[string]interface{}); ok {
+				if _, ok := smtp["to"]; ok {
+					merged.SMTP.To = nil
+				}
+			}
+			if err := json.Unmarshal(encoded, &merged); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status":  "error",
+					"message": "Invalid notifiers format",
+				})
+				return
+			}
+			if err := merged.Validate(); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status":  "error",
+					"message": fmt.Sprintf("Invalid notifiers: %v", err),
+				})
+				return
+			}
+			notifiers = &merged
+		}
+	}
+
+	var seasons *terrarium.SeasonalProfile
+	if rawSeasons, ok := updateData["seasons"].(map[string]interface{}); ok {
+		encoded, _ := json.Marshal(rawSeasons)
+		merged := api.terrarium.GetSettings().Seasons
+		// json decodes into the existing backing array, which is the live
+		// one; sent keyframes replace the list in a fresh slice instead.
+		if _, ok := rawSeasons["keyframes"]; ok {
+			merged.Keyframes = nil
+		}
+		if err := json.Unmarshal(encoded, &merged); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Invalid seasons format",
+			})
+			return
+		}
+		if err := terrarium.ValidateSeasons(merged); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Invalid seasons: %v", err),
+			})
+			return
+		}
+		seasons = &merged
+	}
+
+	var mqttSettings *terrarium.MQTTSettings
+	if rawMQTT, ok := updateData["mqtt"].(map[string]interface{}); ok {
+		encoded, _ := json.Marshal(rawMQTT)
+		merged := api.terrarium.GetSettings().MQTT
+		if err := json.Unmarshal(encoded, &merged); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Invalid mqtt format",
+			})
+			return
+		}
+		if err := mqttbridge.ValidateSettings(merged); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Invalid mqtt settings: %v", err),
+			})
+			return
+		}
+		mqttSettings = &merged
+	}
+
+	var access *auth.Settings
+	if rawAuth, ok := updateData["auth"].(map[string]interface{}); ok {
+		current := api.terrarium.GetSettings().Auth
+		merged := current
+		// Users and tokens have their own routes; GET /settings omits
+		// their hashes, so they are never taken from here.
+		delete(rawAuth, "users")
+		delete(rawAuth, "tokens")
+		// A sent allowlist replaces the live one in a fresh slice; json
+		// would otherwise decode into the live backing array.
+		if _, ok := rawAuth["allowed_origins"]; ok {
+			merged.AllowedOrigins = nil
+		}
+		encoded, _ := json.Marshal(rawAuth)
+		if err := json.Unmarshal(encoded, &merged); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Invalid auth format",
+			})
+			return
+		}
+		merged.Users, merged.Tokens = current.Users, current.Tokens
+		if err := merged.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Invalid auth settings: %v", err),
+			})
+			return
+		}
+		access = &merged
+	}
+
+	var zones []terrarium.ZoneSettings
+	if rawZones, ok := updateData["zones"]; ok {
+		encoded, _ := json.Marshal(rawZones)
+		if err := json.Unmarshal(encoded, &zones); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Invalid zones format",
+			})
+			return
+		}
+		if err := terrarium.ValidateZones(zones); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Invalid zones: %v", err),
+			})
+			return
+		}
+	}
+
+	var simulationParams *simulation.Params
+	if rawParams, ok := updateData["simulation"].(map[string]interface{}); ok {
+		encoded, _ := json.Marshal(rawParams)
+		merged := api.terrarium.GetSettings().Simulation
+		if err := json.Unmarshal(encoded, &merged); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Invalid simulation format",
+			})
+			return
+		}
+		if err := merged.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Invalid simulation parameters: %v", err),
+			})
+			return
+		}
+		simulationParams = &merged
+	}
+
+	var pins *gpio.PinConfig
+	if rawPins, ok := updateData["gpio"].(map[string]interface{}); ok {
+		encoded, _ := json.Marshal(rawPins)
+		merged := api.terrarium.GetSettings().GPIO
+		if err := json.Unmarshal(encoded, &merged); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Invalid gpio format",
+			})
+			return
+		}
+		if err := merged.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Invalid gpio settings: %v", err),
+			})
+			return
+		}
+		pins = &merged
+	}
+
 	api.terrarium.UpdateSettings(func(s *terrarium.TerrariumSettings) {
 		if lightSchedule, ok := updateData["light_schedule"].(map[string]interface{}); ok {
-			if start, ok := lightSchedule["start_time"].(string); ok {
-				s.LightSchedule.StartTime = start
+			if lightWindows != nil {
+				s.LightSchedule.Windows = lightWindows
 			}
-			if end, ok := lightSchedule["end_time"].(string); ok {
-				s.LightSchedule.EndTime = end
+			if mode, ok := lightSchedule["mode"].(string); ok {
+				s.LightSchedule.Mode = mode
+			}
+			if solar != nil {
+				s.LightSchedule.Solar = *solar
 			}
 			if enabled, ok := lightSchedule["enabled"].(bool); ok {
 				s.LightSchedule.Enabled = enabled
@@ -107,21 +578,90 @@ func (api *WebAPI) updateSettings(c *gin.Context) {
 			if humidity, ok := targets["humidity"].(float64); ok {
 				s.Targets.Humidity = float32(humidity)
 			}
-		}
-
-		if pump, ok := updateData["pump_settings"].(map[string]interface{}); ok {
-			if duration, ok := pump["duration_seconds"].(float64); ok {
-				s.PumpSettings.DurationSeconds = int(duration)
+			if night != nil {
+				s.Targets.Night = *night
+			}
+			if temperatureBand != nil {
+				s.Targets.TemperatureBand = *temperatureBand
 			}
-			if interval, ok := pump["min_interval"].(float64); ok {
-				s.PumpSettings.MinInterval = int(interval)
+			if humidityBand != nil {
+				s.Targets.HumidityBand = *humidityBand
 			}
 		}
 
+		if pumpSettings != nil {
+			s.PumpSettings = *pumpSettings
+		}
+
+		if heaterPID != nil {
+			s.HeaterPID = *heaterPID
+		}
+
+		if seasons != nil {
+			s.Seasons = *seasons
+		}
+
+		if alertRules != nil {
+			s.Alerts.Rules = alertRules
+		}
+		if notifiers != nil {
+			s.Alerts.Notifiers = *notifiers
+		}
+
 		if pause, ok := updateData["cycle_pause"].(float64); ok {
 			s.CyclePause = int(pause)
 		}
 
+		if driver, ok := updateData["sensor_driver"].(string); ok {
+			s.SensorDriver = driver
+		}
+
+		if address, ok := updateData["sensor_address"].(float64); ok {
+			s.SensorAddress = uint16(address)
+		}
+
+		if probes, ok := updateData["probe_names"].(map[string]interface{}); ok {
+			// The control loop reads the live map, so fill a new one and
+			// swap it in whole.
+			names := make(map[string]string, len(probes))
+			for id, name := range probes {
+				if name, ok := name.(string); ok {
+					names[id] = name
+				}
+			}
+			s.ProbeNames = names
+		}
+
+		if input, ok := updateData["heater_input"].(string); ok {
+			s.HeaterInput = input
+		}
+
+		if zones != nil {
+			s.Zones = zones
+		}
+
+		if gradient, ok := updateData["min_gradient"].(float64); ok && gradient >= 0 {
+			s.MinGradient = float32(gradient)
+		}
+
+		if simulationParams != nil {
+			s.Simulation = *simulationParams
+		}
+
+		if pins != nil {
+			s.GPIO = *pins
+		}
+
+		if mqttSettings != nil {
+			s.MQTT = *mqttSettings
+		}
+
+		if access != nil {
+			s.Auth.Enabled = access.Enabled
+			s.Auth.AllowedOrigins = access.AllowedOrigins
+			s.Auth.SessionHours = access.SessionHours
+		}
+
 		if mock, ok := updateData["use_mock_data"].(bool); ok {
 			s.UseMockData = mock
 		}
@@ -133,6 +673,15 @@ func (api *WebAPI) updateSettings(c *gin.Context) {
 	})
 }
 
+// mergeControlBand applies the fields sent in raw to band.
+func mergeControlBand(raw map[string]interface{}, band terrarium.ControlBand) (terrarium.ControlBand, error) {
+	encoded, _ := json.Marshal(raw)
+	if err := json.Unmarshal(encoded, &band); err != nil {
+		return band, fmt.Errorf("invalid format")
+	}
+	return band, band.Validate()
+}
+
 func (api *WebAPI) resetSettings(c *gin.Context) {
 	api.terrarium.ResetSettings()
 	c.JSON(http.StatusOK, gin.H{
@@ -149,10 +698,15 @@ func (api *WebAPI) getHealth(c *gin.Context) {
 		healthStatus = "critical"
 	} else if state.SystemMode == "error" {
 		healthStatus = "degraded"
-	} else if state.SensorError {
+	} else if state.SensorError || state.GradientAlarm || state.RelayFault != "" {
 		healthStatus = "warning"
 	}
 
+	zoneHealth := gin.H{}
+	for _, zone := range state.Zones {
+		zoneHealth[zone.Name] = zone.Status
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": healthStatus,
 		"components": gin.H{
@@ -162,9 +716,21 @@ func (api *WebAPI) getHealth(c *gin.Context) {
 				}
 				return "ok"
 			}(),
-			"relays":       "ok",
+			"relays": func() string {
+				if state.RelayFault != "" {
+					return "fault"
+				}
+				return "ok"
+			}(),
 			"control_loop": "running",
 			"api_server":   "running",
+			"zones":        zoneHealth,
+			"gradient": func() string {
+				if state.GradientAlarm {
+					return "collapsed"
+				}
+				return "ok"
+			}(),
 		},
 		"uptime_seconds": int(time.Since(state.Uptime).Seconds()),
 		"cycle_count":    state.CycleCount,
@@ -179,9 +745,13 @@ func (api *WebAPI) toggleMockData(c *gin.Context) {
 		s.UseMockData = !settings.UseMockData
 	})
 
+	message := fmt.Sprintf("Simulation mode: %v", api.terrarium.Simulated())
+	if fallback := api.terrarium.GetState().SimulationFallback; fallback != "" {
+		message += fmt.Sprintf(" (fallback: %s)", fallback)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
-		"message": fmt.Sprintf("Simulation mode: %v", !settings.UseMockData),
+		"message": message,
 	})
 }
 
@@ -200,41 +770,148 @@ func (api *WebAPI) testSensor(c *gin.Context) {
 		"data": gin.H{
 			"temperature": reading.Temperature,
 			"humidity":    reading.Humidity,
-			"valid":       reading.Valid,
-			"timestamp":   time.Now().Format(time.RFC3339),
+			"quality":     reading.Quality,
+			"timestamp":   reading.Timestamp.Format(time.RFC3339),
 		},
 	})
 }
 
-func (api *WebAPI) SetupRouter() *gin.Engine {
-	gin.SetMode(gin.ReleaseMode)
-	router := gin.Default()
-
-	router.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(200)
+func (api *WebAPI) sensorHeaterPulse(c *gin.Context) {
+	var request struct {
+		Seconds float64 `json:"seconds"`
+	}
+	request.Seconds = 1
+	if c.Request.ContentLength > 0 {
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Invalid data format",
+			})
 			return
 		}
+	}
+	if request.Seconds <= 0 || request.Seconds > 10 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "seconds must be between 0 and 10",
+		})
+		return
+	}
+
+	duration := time.Duration(request.Seconds * float64(time.Second))
+	if err := api.controller.SensorHeaterPulse(duration); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Heater pulse failed: %v", err),
+		})
+		return
+	}
 
-		c.Next()
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": fmt.Sprintf("Sensor heater pulsed for %v", duration),
 	})
+}
+
+func (api *WebAPI) setRelayOverride(c *gin.Context) {
+	relay := c.Param("relay")
+
+	var request struct {
+		State           *bool   `json:"state"`
+		DurationSeconds float64 `json:"duration_seconds"`
+	}
+	if err := c.BindJSON(&request); err != nil || request.State == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Expected {\"state\": true|false, \"duration_seconds\": optional}",
+		})
+		return
+	}
+	if request.DurationSeconds < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "duration_seconds must not be negative",
+		})
+		return
+	}
+
+	duration := time.Duration(request.DurationSeconds * float64(time.Second))
+	if err := api.controller.SetOverride(relay, *request.State, duration); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Override failed: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": fmt.Sprintf("Relay %s overridden to %v", relay, *request.State),
+		"data":    api.terrarium.GetState().Overrides[relay],
+	})
+}
+
+func (api *WebAPI) clearRelayOverride(c *gin.Context) {
+	relay := c.Param("relay")
+	if !api.controller.ClearOverride(relay) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("No active override on %s", relay),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": fmt.Sprintf("Relay %s returned to automatic control", relay),
+	})
+}
+
+func (api *WebAPI) SetupRouter() *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.Default()
+
+	router.Use(api.cors)
 
 	apiRoute := router.Group("/api/v1")
 	{
-		apiRoute.GET("/state", api.getState)
-		apiRoute.GET("/history", api.getHistory)
-		apiRoute.GET("/settings", api.getSettings)
-		apiRoute.PUT("/settings", api.updateSettings)
-		apiRoute.POST("/settings/reset", api.resetSettings)
 		apiRoute.GET("/health", api.getHealth)
-		apiRoute.POST("/mock", api.toggleMockData)
-		apiRoute.GET("/sensor/test", api.testSensor)
+		apiRoute.POST("/auth/login", api.login)
+		apiRoute.POST("/auth/logout", api.logout)
+		apiRoute.GET("/auth/me", api.getIdentity)
 	}
 
+	reader := apiRoute.Group("", api.require(auth.RoleRead))
+	{
+		reader.GET("/state", api.getState)
+		reader.GET("/stream", api.stream)
+		reader.GET("/history", api.getHistory)
+		reader.GET("/settings", api.getSettings)
+		reader.GET("/sensor/test", api.testSensor)
+		reader.GET("/alerts", api.getAlerts)
+	}
+
+	admin := apiRoute.Group("", api.require(auth.RoleAdmin))
+	{
+		admin.PUT("/settings", api.updateSettings)
+		admin.POST("/settings/reset", api.resetSettings)
+		admin.POST("/mock", api.toggleMockData)
+		admin.POST("/sensor/heater", api.sensorHeaterPulse)
+		admin.POST("/relays/:relay", api.setRelayOverride)
+		admin.DELETE("/relays/:relay", api.clearRelayOverride)
+		admin.POST("/alerts/test", api.testNotifiers)
+		admin.POST("/alerts/:id/ack", api.acknowledgeAlert)
+		admin.POST("/alerts/:id/snooze", api.snoozeAlert)
+		admin.DELETE("/alerts/:id/snooze", api.unsnoozeAlert)
+		admin.GET("/auth", api.getAuth)
+		admin.PUT("/auth/users/:username", api.putUser)
+		admin.DELETE("/auth/users/:username", api.deleteUser)
+		admin.POST("/auth/tokens", api.createToken)
+		admin.DELETE("/auth/tokens/:name", api.deleteToken)
+	}
+
+	router.GET("/metrics", api.require(auth.RoleRead), api.getMetrics)
+
 	router.StaticFile("/", "./static/index.html")
 	router.Static("/static", "./static")
 
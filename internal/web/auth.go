@@ -0,0 +1,409 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/undeadpelmen/new-client/internal/auth"
+	"github.com/undeadpelmen/new-client/internal/terrarium"
+)
+
+const (
+	sessionCookie = "terrarium_session"
+	identityKey   = "identity"
+)
+
+// identity is the caller a request was authenticated as.
+type identity struct {
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+	Method string `json:"method"`
+}
+
+// cors answers browsers on the configured origins; everyone else only
+// gets same-origin access.
+func (api *WebAPI) cors(c *gin.Context) {
+	if origin := c.GetHeader("Origin"); origin != "" {
+		allowed, credentials := api.terrarium.GetSettings().Auth.OriginAllowed(origin)
+		if allowed {
+			header := c.Writer.Header()
+			header.Add("Vary", "Origin")
+			if credentials {
+				header.Set("Access-Control-Allow-Origin", origin)
+				header.Set("Access-Control-Allow-Credentials", "true")
+			} else {
+				header.Set("Access-Control-Allow-Origin", "*")
+			}
+			header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			header.Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		}
+	}
+
+	if c.Request.Method == "OPTIONS" {
+		c.AbortWithStatus(200)
+		return
+	}
+
+	c.Next()
+}
+
+// authenticate resolves the caller from a bearer token or session cookie.
+// With authentication disabled every caller is an admin.
+func (api *WebAPI) authenticate(c *gin.Context) (identity, bool) {
+	settings := api.terrarium.GetSettings().Auth
+	if !settings.Enabled {
+		return identity{Name: "anonymous", Role: auth.RoleAdmin, Method: "none"}, true
+	}
+
+	if header := c.GetHeader("Authorization"); header != "" {
+		plain, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			return identity{}, false
+		}
+		token, ok := settings.LookupToken(strings.TrimSpace(plain))
+		if !ok {
+			return identity{}, false
+		}
+		return identity{Name: token.Name, Role: token.Role, Method: "token"}, true
+	}
+
+	id, err := c.Cookie(sessionCookie)
+	if err != nil {
+		return identity{}, false
+	}
+	session, ok := api.sessions.Get(id, time.Now())
+	if !ok {
+		return identity{}, false
+	}
+	// Take the role from the current user list so demotions and removals
+	// apply to open sessions.
+	user, ok := settings.User(session.Username)
+	if !ok {
+		api.sessions.Delete(id)
+		return identity{}, false
+	}
+	return identity{Name: user.Username, Role: user.Role, Method: "session"}, true
+}
+
+// require admits callers holding at least role.
+func (api *WebAPI) require(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		who, ok := api.authenticate(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": "Authentication required",
+			})
+			return
+		}
+		if !auth.RoleAllows(who.Role, role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("Requires the %s role", role),
+			})
+			return
+		}
+		// Session cookies ride along on cross-site requests in older
+		// browsers; refuse changes coming from foreign pages.
+		if who.Method == "session" && c.Request.Method != http.MethodGet && !api.trustedOrigin(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"status":  "error",
+				"message": "Cross-origin request refused",
+			})
+			return
+		}
+		c.Set(identityKey, who)
+		c.Next()
+	}
+}
+
+func (api *WebAPI) trustedOrigin(c *gin.Context) bool {
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, c.Request.Host) {
+		return true
+	}
+	_, credentials := api.terrarium.GetSettings().Auth.OriginAllowed(origin)
+	return credentials
+}
+
+func (api *WebAPI) login(c *gin.Context) {
+	var request struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := c.BindJSON(&request); err != nil || request.Username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Expected {\"username\": ..., \"password\": ...}",
+		})
+		return
+	}
+
+	settings := api.terrarium.GetSettings().Auth
+	user, ok := settings.Login(request.Username, request.Password)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  "error",
+			"message": "Invalid username or password",
+		})
+		return
+	}
+
+	ttl := auth.DefaultSessionTTL
+	if settings.SessionHours > 0 {
+		ttl = time.Duration(settings.SessionHours) * time.Hour
+	}
+	session, err := api.sessions.Create(user.Username, ttl, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Login failed: %v", err),
+		})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(sessionCookie, session.ID, int(ttl.Seconds()), "/", "", c.Request.TLS != nil, true)
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"username": user.Username,
+			"role":     user.Role,
+			"expires":  session.Expires.Format(time.RFC3339),
+		},
+	})
+}
+
+func (api *WebAPI) logout(c *gin.Context) {
+	if id, err := c.Cookie(sessionCookie); err == nil {
+		api.sessions.Delete(id)
+	}
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(sessionCookie, "", -1, "/", "", c.Request.TLS != nil, true)
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Logged out",
+	})
+}
+
+func (api *WebAPI) getIdentity(c *gin.Context) {
+	who, ok := api.authenticate(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  "error",
+			"message": "Authentication required",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   who,
+	})
+}
+
+func (api *WebAPI) getAuth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   api.terrarium.GetSettings().Auth.Redacted(),
+	})
+}
+
+// updateAuth applies change to a copy of the auth settings and stores it
+// only if the result is valid.
+func (api *WebAPI) updateAuth(change func(*auth.Settings)) error {
+	var err error
+	api.terrarium.UpdateSettings(func(s *terrarium.TerrariumSettings) {
+		updated := s.Auth
+		updated.Users = append([]auth.User(nil), s.Auth.Users...)
+		updated.Tokens = append([]auth.Token(nil), s.Auth.Tokens...)
+		change(&updated)
+		if err = updated.Validate(); err == nil {
+			s.Auth = updated
+		}
+	})
+	return err
+}
+
+func (api *WebAPI) putUser(c *gin.Context) {
+	username := c.Param("username")
+
+	var request struct {
+		Password string `json:"password"`
+		Role     string `json:"role"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Expected {\"password\": ..., \"role\": \"read\"|\"admin\"}",
+		})
+		return
+	}
+	if request.Role != "" && !auth.ValidRole(request.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Unknown role %q", request.Role),
+		})
+		return
+	}
+
+	var hash string
+	if request.Password != "" {
+		var err error
+		if hash, err = auth.HashPassword(request.Password); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+
+	err := api.updateAuth(func(s *auth.Settings) {
+		for i := range s.Users {
+			if s.Users[i].Username == username {
+				if hash != "" {
+					s.Users[i].PasswordHash = hash
+				}
+				if request.Role != "" {
+					s.Users[i].Role = request.Role
+				}
+				return
+			}
+		}
+		s.Users = append(s.Users, auth.User{Username: username, PasswordHash: hash, Role: request.Role})
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Invalid user: %v", err),
+		})
+		return
+	}
+	if hash != "" {
+		api.sessions.DeleteUser(username)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": fmt.Sprintf("User %s saved", username),
+	})
+}
+
+func (api *WebAPI) deleteUser(c *gin.Context) {
+	username := c.Param("username")
+
+	found := false
+	err := api.updateAuth(func(s *auth.Settings) {
+		for i, user := range s.Users {
+			if user.Username == username {
+				s.Users = append(s.Users[:i], s.Users[i+1:]...)
+				found = true
+				return
+			}
+		}
+	})
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Unknown user %s", username),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Cannot delete user: %v", err),
+		})
+		return
+	}
+	api.sessions.DeleteUser(username)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": fmt.Sprintf("User %s deleted", username),
+	})
+}
+
+func (api *WebAPI) createToken(c *gin.Context) {
+	var request struct {
+		Name string `json:"name"`
+		Role string `json:"role"`
+	}
+	if err := c.BindJSON(&request); err != nil || request.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Expected {\"name\": ..., \"role\": \"read\"|\"admin\"}",
+		})
+		return
+	}
+
+	plain, hash, err := auth.NewToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Token generation failed: %v", err),
+		})
+		return
+	}
+	token := auth.Token{Name: request.Name, Hash: hash, Role: request.Role, Created: time.Now().UTC()}
+	if err := api.updateAuth(func(s *auth.Settings) {
+		s.Tokens = append(s.Tokens, token)
+	}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Invalid token: %v", err),
+		})
+		return
+	}
+
+	// The plain token is only ever shown here.
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"name":  token.Name,
+			"role":  token.Role,
+			"token": plain,
+		},
+	})
+}
+
+func (api *WebAPI) deleteToken(c *gin.Context) {
+	name := c.Param("name")
+
+	found := false
+	err := api.updateAuth(func(s *auth.Settings) {
+		for i, token := range s.Tokens {
+			if token.Name == name {
+				s.Tokens = append(s.Tokens[:i], s.Tokens[i+1:]...)
+				found = true
+				return
+			}
+		}
+	})
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Unknown token %s", name),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("Cannot delete token: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": fmt.Sprintf("Token %s deleted", name),
+	})
+}
@@ -0,0 +1,121 @@
+package web
+
+import (
+	"bytes"
+	"cmp"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/undeadpelmen/new-client/internal/alert"
+	"github.com/undeadpelmen/new-client/internal/metrics"
+	"github.com/undeadpelmen/new-client/internal/terrarium"
+)
+
+var systemModes = []string{"auto", "error", "critical"}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// getMetrics serves the Prometheus text exposition format.
+func (api *WebAPI) getMetrics(c *gin.Context) {
+	state := api.terrarium.GetState()
+	counters := api.controller.Metrics()
+
+	var buf bytes.Buffer
+	mw := metrics.NewWriter(&buf)
+
+	mw.Gauge("terrarium_temperature_celsius", "Air temperature from the climate sensor.", nil, float64(state.CurrentTemp))
+	mw.Gauge("terrarium_humidity_percent", "Relative humidity from the climate sensor.", nil, float64(state.CurrentHumidity))
+	if state.CurrentPressure > 0 {
+		mw.Gauge("terrarium_pressure_hpa", "Barometric pressure from the climate sensor.", nil, float64(state.CurrentPressure))
+	}
+	channels := make([]string, 0, len(state.Channels))
+	for name := range state.Channels {
+		channels = append(channels, name)
+	}
+	slices.Sort(channels)
+	for _, name := range channels {
+		mw.Gauge("terrarium_channel_temperature_celsius", "Temperature of a named probe channel.",
+			metrics.Labels{"channel": name}, float64(state.Channels[name]))
+	}
+	for _, zone := range state.Zones {
+		if zone.Status == terrarium.ZoneStatusNoData {
+			continue
+		}
+		mw.Gauge("terrarium_zone_temperature_celsius", "Temperature of a thermal zone.",
+			metrics.Labels{"zone": zone.Name, "role": zone.Role}, float64(zone.Temperature))
+	}
+	if len(state.Zones) > 0 {
+		mw.Gauge("terrarium_gradient_celsius", "Hot minus cold zone temperature.", nil, float64(state.Gradient))
+	}
+	mw.Gauge("terrarium_target_temperature_celsius", "Temperature target in force.", nil, float64(state.TargetTemp))
+	mw.Gauge("terrarium_target_humidity_percent", "Humidity target in force.", nil, float64(state.TargetHumidity))
+
+	relays := []struct {
+		name string
+		on   bool
+	}{
+		{terrarium.RelayLight, state.LightRelay},
+		{terrarium.RelayHeater, state.HeaterRelay},
+		{terrarium.RelayPump, state.PumpRelay},
+	}
+	for _, relay := range relays {
+		mw.Gauge("terrarium_relay_on", "Whether the relay is switched on.",
+			metrics.Labels{"relay": relay.name}, boolValue(relay.on))
+	}
+
+	mw.Gauge("terrarium_sensor_error", "Whether the last climate sensor read failed.", nil, boolValue(state.SensorError))
+	for _, mode := range systemModes {
+		mw.Gauge("terrarium_system_mode", "Current system mode; 1 for the active mode.",
+			metrics.Labels{"mode": mode}, boolValue(state.SystemMode == mode))
+	}
+	mw.Gauge("terrarium_uptime_seconds", "Seconds since the controller started.", nil, time.Since(state.Uptime).Seconds())
+
+	firing := map[string]int{}
+	for _, status := range api.controller.Alerts() {
+		if status.State == alert.StateFiring {
+			firing[status.Rule.Severity]++
+		}
+	}
+	for _, severity := range []string{alert.SeverityInfo, alert.SeverityWarning, alert.SeverityCritical} {
+		mw.Gauge("terrarium_alerts_firing", "Alerts currently firing.",
+			metrics.Labels{"severity": severity}, float64(firing[severity]))
+	}
+
+	mw.Counter("terrarium_control_cycles_total", "Control loop cycles run.", nil, float64(counters.Cycles))
+
+	failures := make([]terrarium.SensorFailureKey, 0, len(counters.SensorFailures))
+	for key := range counters.SensorFailures {
+		failures = append(failures, key)
+	}
+	slices.SortFunc(failures, func(a, b terrarium.SensorFailureKey) int {
+		return cmp.Or(strings.Compare(a.Source, b.Source), strings.Compare(a.Type, b.Type))
+	})
+	for _, key := range failures {
+		mw.Counter("terrarium_sensor_read_failures_total", "Failed sensor reads by sensor and failure type.",
+			metrics.Labels{"sensor": key.Source, "type": key.Type}, float64(counters.SensorFailures[key]))
+	}
+
+	for _, relay := range relays {
+		mw.Counter("terrarium_relay_switches_total", "Relay state changes.",
+			metrics.Labels{"relay": relay.name}, float64(counters.RelaySwitches[relay.name]))
+	}
+
+	mw.Histogram("terrarium_control_loop_duration_seconds", "Duration of one control loop cycle.",
+		nil, counters.CycleDuration)
+
+	if err := mw.Err(); err != nil {
+		log.Printf("Failed to render metrics: %v", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+}
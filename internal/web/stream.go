@@ -0,0 +1,98 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/undeadpelmen/new-client/internal/pubsub"
+)
+
+const (
+	streamBuffer    = 64
+	streamHeartbeat = 15 * time.Second
+)
+
+// Close ends every open stream so the HTTP server can shut down.
+func (api *WebAPI) Close() {
+	api.closeOnce.Do(func() { close(api.done) })
+}
+
+func writeEvent(w gin.ResponseWriter, event string, data any) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, encoded); err != nil {
+		return err
+	}
+	w.Flush()
+	return nil
+}
+
+// stream pushes controller events as Server-Sent Events. State events carry
+// the same payload as GET /state; the optional types query parameter
+// (comma separated) limits which events are sent.
+func (api *WebAPI) stream(c *gin.Context) {
+	wanted := map[string]bool{}
+	if types := c.Query("types"); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			wanted[strings.TrimSpace(t)] = true
+		}
+	}
+	want := func(t string) bool { return len(wanted) == 0 || wanted[t] }
+
+	sub := api.controller.Events().Subscribe(streamBuffer)
+	defer sub.Close()
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if want(pubsub.TypeState) {
+		if err := writeEvent(c.Writer, pubsub.TypeState, api.stateData()); err != nil {
+			return
+		}
+	} else {
+		c.Writer.Flush()
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-api.done:
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": keepalive\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case msg, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if !want(msg.Type) {
+				continue
+			}
+			data := msg.Data
+			if msg.Type == pubsub.TypeState {
+				data = api.stateData()
+			}
+			if err := writeEvent(c.Writer, msg.Type, data); err != nil {
+				log.Printf("Stream client %s disconnected: %v", c.ClientIP(), err)
+				return
+			}
+		}
+	}
+}
@@ -2,51 +2,89 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/undeadpelmen/new-client/internal/gpio"
+	"github.com/undeadpelmen/new-client/internal/mqttbridge"
+	"github.com/undeadpelmen/new-client/internal/sensor"
 	"github.com/undeadpelmen/new-client/internal/terrarium"
 	"github.com/undeadpelmen/new-client/internal/web"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "simulate":
+			runSimulate(os.Args[2:])
+			return
+		case "passwd":
+			runPasswd(os.Args[2:])
+			return
+		}
+	}
+
+	settingsPath := flag.String("settings", "terrarium-settings.json", "path to the persisted settings file")
+	oneWireRoot := flag.String("w1-root", sensor.DefaultOneWireRoot, "sysfs root of the 1-Wire bus for DS18B20 probes (empty disables them)")
+	historyDir := flag.String("history-dir", "history", "directory for history segment files (empty keeps history in memory)")
+	listenAddr := flag.String("listen", ":8080", "address of the web server")
+	useTLS := flag.Bool("tls", false, "serve HTTPS; without -tls-cert a self-signed certificate is generated")
+	tlsCert := flag.String("tls-cert", "", "PEM certificate file (implies -tls)")
+	tlsKey := flag.String("tls-key", "", "PEM private key file for -tls-cert")
+	tlsDir := flag.String("tls-dir", "tls", "directory for the generated self-signed certificate")
+	tlsHosts := flag.String("tls-hosts", "", "comma-separated extra host names or IPs for the self-signed certificate")
+	redirectAddr := flag.String("http-redirect", "", "also listen on this address and redirect plain HTTP to HTTPS, e.g. :80")
+	flag.Parse()
+
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	log.Println("Terrarium control system v2.0")
 
 	terrariumInstance := terrarium.NewTerrarium()
+	terrariumInstance.LoadSettings(terrarium.NewSettingsStore(*settingsPath))
+
+	if *historyDir != "" {
+		historyStore, err := terrarium.NewSegmentHistoryStore(*historyDir)
+		if err != nil {
+			log.Printf("History store error: %v", err)
+			log.Println("Keeping history in memory only")
+		} else {
+			terrariumInstance.SetHistoryStore(historyStore)
+		}
+	}
 
-	var relayController *gpio.RelayController
-	var err error
+	var relayDriver gpio.RelayDriver
 
-	relayController, err = gpio.NewRelayController()
+	relayController, err := gpio.NewRelayController(terrariumInstance.GetSettings().GPIO)
 	if err != nil {
 		log.Printf("GPIO initialization error: %v", err)
 		log.Println("Switching to simulation mode")
-		terrariumInstance.UpdateSettings(func(s *terrarium.TerrariumSettings) {
-			s.UseMockData = true
-		})
-		relayController = nil
+		terrariumInstance.FallBackToSimulation(fmt.Sprintf("GPIO initialization failed: %v", err))
+		relayDriver = gpio.NewLoggingRelays(gpio.NewSimulatedRelays(), "sim")
 	} else {
 		log.Println("GPIO initialized successfully")
+		relayDriver = relayController
 	}
 
-	controller := terrarium.NewTerrariumController(terrariumInstance, relayController)
+	controller := terrarium.NewTerrariumController(terrariumInstance, relayDriver)
+	if *oneWireRoot != "" {
+		controller.EnableOneWire(sensor.NewOneWireBus(*oneWireRoot))
+	}
 
 	if relayController != nil {
-		log.Println("Testing DHT22 sensor...")
+		log.Printf("Testing %s sensor...", terrariumInstance.GetSettings().SensorDriver)
 		if reading, err := controller.TestSensor(); err != nil {
-			log.Printf("DHT22 not responding: %v", err)
+			log.Printf("Sensor not responding: %v", err)
 			log.Println("Switching to simulation mode")
-			terrariumInstance.UpdateSettings(func(s *terrarium.TerrariumSettings) {
-				s.UseMockData = true
-			})
+			terrariumInstance.FallBackToSimulation(fmt.Sprintf("sensor not responding: %v", err))
 		} else {
-			log.Printf("DHT22 working: T=%.1f°C, H=%.1f%%",
+			log.Printf("Sensor working: T=%.1f°C, H=%.1f%%",
 				reading.Temperature, reading.Humidity)
 		}
 	}
@@ -57,26 +95,78 @@ func main() {
 	log.Println("Starting main control loop...")
 	go controller.ControlLoop(ctx)
 
+	var bridgeDone chan struct{}
+	if mqttSettings := terrariumInstance.GetSettings().MQTT; mqttSettings.Enabled {
+		if err := mqttbridge.ValidateSettings(mqttSettings); err != nil {
+			log.Printf("MQTT disabled: %v", err)
+		} else {
+			bridge := mqttbridge.New(mqttSettings, terrariumInstance, controller)
+			bridgeDone = make(chan struct{})
+			go func() {
+				bridge.Run(ctx)
+				close(bridgeDone)
+			}()
+			log.Printf("MQTT bridge publishing to %s under %s/", mqttSettings.Broker, mqttSettings.TopicPrefix)
+		}
+	}
+
+	if !terrariumInstance.GetSettings().Auth.Enabled {
+		log.Println("API authentication is disabled; anyone who can reach the port has admin access")
+	}
+
 	webAPI := web.NewWebAPI(terrariumInstance, controller)
 	router := webAPI.SetupRouter()
 
 	server := &http.Server{
-		Addr:    ":8080",
+		Addr:    *listenAddr,
 		Handler: router,
 	}
+	server.RegisterOnShutdown(webAPI.Close)
+
+	scheme := "http"
+	var redirectServer *http.Server
+	if *useTLS || *tlsCert != "" {
+		tlsConfig, err := loadTLSConfig(*tlsCert, *tlsKey, *tlsDir, *tlsHosts)
+		if err != nil {
+			log.Fatalf("TLS setup failed: %v", err)
+		}
+		server.TLSConfig = tlsConfig
+		scheme = "https"
+
+		if *redirectAddr != "" {
+			redirectServer = &http.Server{
+				Addr:    *redirectAddr,
+				Handler: httpsRedirect(*listenAddr),
+			}
+			go func() {
+				log.Printf("Redirecting HTTP on %s to HTTPS", *redirectAddr)
+				if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					log.Printf("HTTP redirect listener error: %v", err)
+				}
+			}()
+		}
+	} else if *redirectAddr != "" {
+		log.Println("Ignoring -http-redirect: HTTPS is not enabled")
+	}
 
 	go func() {
-		log.Println("HTTP server starting on port 8080")
-		log.Println("Web interface: http://localhost:8080")
-		log.Println("API: http://localhost:8080/api/v1/state")
+		log.Printf("%s server starting on %s", strings.ToUpper(scheme), *listenAddr)
+		log.Printf("Web interface: %s://localhost%s", scheme, localPort(*listenAddr))
+		log.Printf("API: %s://localhost%s/api/v1/state", scheme, localPort(*listenAddr))
 
-		if terrariumInstance.GetSettings().UseMockData {
+		if terrariumInstance.Simulated() {
 			log.Println("Mode: SIMULATION")
 		} else {
 			log.Println("Mode: REAL SENSOR")
 		}
 
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if server.TLSConfig != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("HTTP server error: %v", err)
 		}
 	}()
@@ -91,20 +181,29 @@ func main() {
 
 	cancel()
 
+	if bridgeDone != nil {
+		<-bridgeDone
+	}
+
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdownCancel()
 
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		log.Printf("HTTP server shutdown error: %v", err)
 	}
-
-	if relayController != nil {
-		relayController.Shutdown()
+	if redirectServer != nil {
+		redirectServer.Shutdown(shutdownCtx)
 	}
 
+	relayDriver.Shutdown()
+
 	if err := controller.Close(); err != nil {
 		log.Printf("Controller shutdown error: %v", err)
 	}
 
+	if err := terrariumInstance.Close(); err != nil {
+		log.Printf("History store shutdown error: %v", err)
+	}
+
 	log.Println("System stopped gracefully")
 }
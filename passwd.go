@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/undeadpelmen/new-client/internal/auth"
+	"github.com/undeadpelmen/new-client/internal/terrarium"
+)
+
+// runPasswd creates or updates an API user in the settings file, reading
+// the password from stdin. It is the way in for the first admin and after
+// a lost password; stop the controller first, since it rewrites the file.
+func runPasswd(args []string) {
+	fs := flag.NewFlagSet("passwd", flag.ExitOnError)
+	settingsPath := fs.String("settings", "terrarium-settings.json", "settings file to update")
+	username := fs.String("user", "admin", "user to create or update")
+	role := fs.String("role", auth.RoleAdmin, "role: read or admin")
+	enable := fs.Bool("enable", false, "also turn API authentication on")
+	fs.Parse(args)
+
+	if !auth.ValidRole(*role) {
+		log.Fatalf("Unknown role %q", *role)
+	}
+
+	fmt.Fprintf(os.Stderr, "Password for %s: ", *username)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatalf("Failed to read password: %v", err)
+	}
+	hash, err := auth.HashPassword(strings.TrimRight(line, "\r\n"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	terrariumInstance := terrarium.NewTerrarium()
+	terrariumInstance.LoadSettings(terrarium.NewSettingsStore(*settingsPath))
+
+	updated := terrariumInstance.GetSettings().Auth
+	updated.Users = append([]auth.User(nil), updated.Users...)
+	found := false
+	for i := range updated.Users {
+		if updated.Users[i].Username == *username {
+			updated.Users[i].PasswordHash = hash
+			updated.Users[i].Role = *role
+			found = true
+		}
+	}
+	if !found {
+		updated.Users = append(updated.Users, auth.User{Username: *username, PasswordHash: hash, Role: *role})
+	}
+	if *enable {
+		updated.Enabled = true
+	}
+	if err := updated.Validate(); err != nil {
+		log.Fatalf("Invalid authentication settings: %v", err)
+	}
+
+	terrariumInstance.UpdateSettings(func(s *terrarium.TerrariumSettings) {
+		s.Auth = updated
+	})
+	fmt.Fprintf(os.Stderr, "Saved %s user %s to %s\n", *role, *username, *settingsPath)
+	if !updated.Enabled {
+		fmt.Fprintln(os.Stderr, "Authentication is still disabled; rerun with -enable or set auth.enabled")
+	}
+}
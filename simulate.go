@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/undeadpelmen/new-client/internal/alert"
+	"github.com/undeadpelmen/new-client/internal/gpio"
+	"github.com/undeadpelmen/new-client/internal/terrarium"
+)
+
+// recordingHistory keeps every record of a simulation run in memory.
+type recordingHistory struct {
+	records []terrarium.HistoricalRecord
+}
+
+func (r *recordingHistory) Append(record terrarium.HistoricalRecord) error {
+	r.records = append(r.records, record)
+	return nil
+}
+
+func (r *recordingHistory) Recent(limit int) ([]terrarium.HistoricalRecord, error) {
+	if limit <= 0 || limit > len(r.records) {
+		limit = len(r.records)
+	}
+	return r.records[len(r.records)-limit:], nil
+}
+
+func (r *recordingHistory) Count() int {
+	return len(r.records)
+}
+
+func (r *recordingHistory) Close() error {
+	return nil
+}
+
+// runSimulate executes the control logic against the simulated environment
+// on a manual clock, as fast as the CPU allows.
+func runSimulate(args []string) {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	settingsPath := fs.String("settings", "terrarium-settings.json", "settings file to simulate (never modified)")
+	duration := fs.Duration("duration", 24*time.Hour, "simulated time span")
+	startStr := fs.String("start", "", "simulated start time, RFC3339 (default: today 00:00 local)")
+	outPath := fs.String("out", "", "write the simulated history as JSON lines to this file")
+	verbose := fs.Bool("verbose", false, "show control loop log output")
+	fs.Parse(args)
+
+	y, m, d := time.Now().Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	if *startStr != "" {
+		parsed, err := time.Parse(time.RFC3339, *startStr)
+		if err != nil {
+			log.Fatalf("Invalid start time: %v", err)
+		}
+		start = parsed
+	}
+
+	terrariumInstance := terrarium.NewTerrarium()
+	terrariumInstance.LoadSettingsSnapshot(terrarium.NewSettingsStore(*settingsPath))
+	terrariumInstance.UpdateSettings(func(s *terrarium.TerrariumSettings) {
+		s.UseMockData = true
+		// Simulated alerts are evaluated but never delivered.
+		s.Alerts.Notifiers = alert.NotifierSettings{}
+	})
+	if err := terrariumInstance.GetSettings().Simulation.Validate(); err != nil {
+		log.Fatalf("Invalid simulation parameters in %s: %v", *settingsPath, err)
+	}
+	history := &recordingHistory{}
+	terrariumInstance.SetHistoryStore(history)
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	clock := terrarium.NewManualClock(start)
+	controller := terrarium.NewTerrariumController(terrariumInstance, gpio.NewSimulatedRelays())
+	controller.SetClock(clock)
+
+	began := time.Now()
+	end := start.Add(*duration)
+	for clock.Now().Before(end) {
+		pause := controller.Step()
+		if pause <= 0 {
+			pause = time.Second
+		}
+		clock.Advance(pause)
+	}
+	elapsed := time.Since(began)
+
+	log.SetOutput(os.Stderr)
+
+	if *outPath != "" {
+		if err := writeHistory(*outPath, history.records); err != nil {
+			log.Fatalf("Failed to write history: %v", err)
+		}
+	}
+
+	printSummary(os.Stdout, terrariumInstance.GetSettings(), history.records,
+		controller.Metrics().RelaySwitches, start, end, elapsed)
+}
+
+func writeHistory(path string, records []terrarium.HistoricalRecord) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(f)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+type rangeStats struct {
+	min, max, sum float64
+	inRange       time.Duration
+	total         time.Duration
+	samples       int
+}
+
+func (rs *rangeStats) add(value float64, low, high float64, weight time.Duration) {
+	if rs.samples == 0 || value < rs.min {
+		rs.min = value
+	}
+	if rs.samples == 0 || value > rs.max {
+		rs.max = value
+	}
+	rs.sum += value
+	rs.samples++
+	rs.total += weight
+	if value >= low && value <= high {
+		rs.inRange += weight
+	}
+}
+
+func (rs *rangeStats) String() string {
+	if rs.samples == 0 {
+		return "no data"
+	}
+	return fmt.Sprintf("min %.1f, avg %.1f, max %.1f, in range %.1f%%",
+		rs.min, rs.sum/float64(rs.samples), rs.max,
+		100*rs.inRange.Seconds()/rs.total.Seconds())
+}
+
+// printSummary reports the simulated climate from the history records.
+// Relay switches come from the controller's counters: records are taken
+// once per cycle and miss pump pulses shorter than the cycle pause.
+func printSummary(w io.Writer, settings *terrarium.TerrariumSettings, records []terrarium.HistoricalRecord,
+	switches map[string]int64, start, end time.Time, elapsed time.Duration) {
+	targets := settings.Targets
+	scheduled := settings.Seasons.Enabled || settings.Targets.Night.Enabled
+	tempLabel := fmt.Sprintf("target %.1f..%.1f°C",
+		targets.Temperature-targets.TemperatureBand.Lower, targets.Temperature+targets.TemperatureBand.Upper)
+	humLabel := fmt.Sprintf("target %.1f..%.1f%%",
+		targets.Humidity-targets.HumidityBand.Lower, targets.Humidity+targets.HumidityBand.Upper)
+	if scheduled {
+		tempLabel = fmt.Sprintf("scheduled target -%.1f/+%.1f°C", targets.TemperatureBand.Lower, targets.TemperatureBand.Upper)
+		humLabel = fmt.Sprintf("scheduled target -%.1f/+%.1f%%", targets.HumidityBand.Lower, targets.HumidityBand.Upper)
+	}
+
+	// A heater zone replaces the air band: the heater holds the zone's
+	// reading within its range, shifted by the seasonal and night offset.
+	var heaterZone *terrarium.ZoneSettings
+	for i := range settings.Zones {
+		if settings.Zones[i].Relay == terrarium.ZoneRelayHeater {
+			heaterZone = &settings.Zones[i]
+			break
+		}
+	}
+	if heaterZone != nil {
+		tempLabel = fmt.Sprintf("%s zone target %.1f..%.1f°C", heaterZone.Name, heaterZone.TargetMin, heaterZone.TargetMax)
+		if scheduled {
+			tempLabel = fmt.Sprintf("%s zone scheduled target, %.1f..%.1f°C by day",
+				heaterZone.Name, heaterZone.TargetMin, heaterZone.TargetMax)
+		}
+	}
+
+	var temp, humidity rangeStats
+	var lightOn time.Duration
+	var lightEvents []string
+
+	for i, record := range records {
+		next := end
+		if i+1 < len(records) {
+			next = records[i+1].Timestamp
+		}
+		weight := next.Sub(record.Timestamp)
+
+		targetTemp, targetHumidity := targets.Temperature, targets.Humidity
+		if record.TargetTemp != 0 {
+			targetTemp = record.TargetTemp
+		}
+		if record.TargetHumidity != 0 {
+			targetHumidity = record.TargetHumidity
+		}
+		if heaterZone != nil {
+			offset := targetTemp - targets.Temperature
+			zoneTemp, ok := record.Zones[heaterZone.Name]
+			if !ok {
+				// The controller falls back to the air reading too.
+				zoneTemp = record.Temperature
+			}
+			temp.add(float64(zoneTemp), float64(heaterZone.TargetMin+offset),
+				float64(heaterZone.TargetMax+offset), weight)
+		} else {
+			temp.add(float64(record.Temperature), float64(targetTemp-targets.TemperatureBand.Lower),
+				float64(targetTemp+targets.TemperatureBand.Upper), weight)
+		}
+		humidity.add(float64(record.Humidity), float64(targetHumidity-targets.HumidityBand.Lower),
+			float64(targetHumidity+targets.HumidityBand.Upper), weight)
+		if record.LightOn {
+			lightOn += weight
+		}
+
+		if i == 0 {
+			continue
+		}
+		prev := records[i-1]
+		if record.LightOn != prev.LightOn {
+			state := "off"
+			if record.LightOn {
+				state = "on"
+			}
+			lightEvents = append(lightEvents, fmt.Sprintf("%s %s", record.Timestamp.Format("2006-01-02 15:04"), state))
+		}
+	}
+
+	fmt.Fprintf(w, "Simulated %s to %s (%v) in %v, %d cycles\n",
+		start.Format(time.RFC3339), end.Format(time.RFC3339), end.Sub(start), elapsed.Round(time.Millisecond), len(records))
+	fmt.Fprintf(w, "Temperature (%s): %s\n", tempLabel, temp.String())
+	fmt.Fprintf(w, "Humidity (%s): %s\n", humLabel, humidity.String())
+	fmt.Fprintf(w, "Relay switches: light %d, heater %d, pump %d\n",
+		switches[terrarium.RelayLight], switches[terrarium.RelayHeater], switches[terrarium.RelayPump])
+	fmt.Fprintf(w, "Light on for %v\n", lightOn.Round(time.Minute))
+	for _, event := range lightEvents {
+		fmt.Fprintf(w, "  light %s\n", event)
+	}
+}
@@ -1,46 +1,131 @@
-const api_prefix = 'http://10.218.51.114:8080'
+// Same origin by default; a dashboard served elsewhere must be listed in
+// auth.allowed_origins.
+const api_prefix = ''
+
+function setRelay(id, on) {
+    document.getElementById(id).textContent = on ? 'Вкл' : 'Выкл';
+    document.getElementById(id).className = on ? 'status-on' : 'status-off';
+}
+
+function render(d) {
+    document.getElementById('temp').textContent = d.sensors.temperature.toFixed(1);
+    document.getElementById('humidity').textContent = d.sensors.humidity.toFixed(1);
+
+    setRelay('light-status', d.relays.light);
+    setRelay('heater-status', d.relays.heater);
+    setRelay('pump-status', d.relays.pump);
+
+    document.getElementById('system-mode').textContent = d.system.mode;
+    document.getElementById('cycle-count').textContent = d.system.cycle_count;
+    document.getElementById('uptime').textContent = d.system.uptime;
+
+    document.getElementById('sensor-status').textContent = d.sensors.sensor_error ?
+        'Ошибка датчика!' : 'Датчик OK';
+    document.getElementById('sensor-status').className = d.sensors.sensor_error ? 'error' : '';
+}
+
+function showLogin(visible) {
+    document.getElementById('login').style.display = visible ? '' : 'none';
+}
 
 async function fetchData() {
     try {
-        const response = await fetch(api_prefix + '/api/v1/state');
+        const response = await fetch(api_prefix + '/api/v1/state', { credentials: 'include' });
+        if (response.status === 401) {
+            showLogin(true);
+            return;
+        }
         const data = await response.json();
 
         if (data.status === 'success') {
-            const d = data.data;
-            document.getElementById('temp').textContent = d.sensors.temperature.toFixed(1);
-            document.getElementById('humidity').textContent = d.sensors.humidity.toFixed(1);
+            render(data.data);
+        }
+    } catch (error) {
+        console.error('Ошибка:', error);
+    }
+}
 
-            document.getElementById('light-status').textContent = d.relays.light ? 'Вкл' : 'Выкл';
-            document.getElementById('light-status').className = d.relays.light ? 'status-on' : 'status-off';
+// Live updates arrive over Server-Sent Events; polling is only a fallback
+// for browsers without EventSource or while the stream is down.
+let pollTimer = null;
+let source = null;
 
-            document.getElementById('heater-status').textContent = d.relays.heater ? 'Вкл' : 'Выкл';
-            document.getElementById('heater-status').className = d.relays.heater ? 'status-on' : 'status-off';
+function startPolling() {
+    if (pollTimer === null) {
+        pollTimer = setInterval(fetchData, 10000);
+    }
+}
 
-            document.getElementById('pump-status').textContent = d.relays.pump ? 'Вкл' : 'Выкл';
-            document.getElementById('pump-status').className = d.relays.pump ? 'status-on' : 'status-off';
+function stopPolling() {
+    if (pollTimer !== null) {
+        clearInterval(pollTimer);
+        pollTimer = null;
+    }
+}
 
-            document.getElementById('system-mode').textContent = d.system.mode;
-            document.getElementById('cycle-count').textContent = d.system.cycle_count;
-            document.getElementById('uptime').textContent = d.system.uptime;
+function connectStream() {
+    if (!window.EventSource) {
+        fetchData();
+        startPolling();
+        return;
+    }
 
-            document.getElementById('sensor-status').textContent = d.sensors.sensor_error ?
-                'Ошибка датчика!' : 'Датчик OK';
-            document.getElementById('sensor-status').className = d.sensors.sensor_error ? 'error' : '';
-        }
-    } catch (error) {
-        console.error('Ошибка:', error);
+    if (source !== null) {
+        source.close();
     }
+    source = new EventSource(api_prefix + '/api/v1/stream?types=state,relay,alert',
+        { withCredentials: true });
+    source.onopen = () => {
+        stopPolling();
+        showLogin(false);
+    };
+    source.onerror = startPolling;
+    source.addEventListener('state', (e) => render(JSON.parse(e.data)));
+    source.addEventListener('relay', (e) => {
+        const r = JSON.parse(e.data);
+        setRelay(r.relay + '-status', r.state);
+    });
+    source.addEventListener('alert', (e) => {
+        const a = JSON.parse(e.data);
+        console.warn(`Тревога ${a.rule_id} (${a.severity}): ${a.message}`);
+    });
+}
+
+async function login(event) {
+    event.preventDefault();
+    const response = await fetch(api_prefix + '/api/v1/auth/login', {
+        method: 'POST',
+        credentials: 'include',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({
+            username: document.getElementById('username').value,
+            password: document.getElementById('password').value,
+        }),
+    });
+    const data = await response.json();
+    if (data.status !== 'success') {
+        alert(data.message);
+        return;
+    }
+    document.getElementById('password').value = '';
+    showLogin(false);
+    connectStream();
+}
+
+async function logout() {
+    await fetch(api_prefix + '/api/v1/auth/logout', { method: 'POST', credentials: 'include' });
+    location.reload();
 }
 
 async function toggleMock() {
-    const response = await fetch(api_prefix + '/api/v1/mock', { method: 'POST' });
+    const response = await fetch(api_prefix + '/api/v1/mock', { method: 'POST', credentials: 'include' });
     const data = await response.json();
     alert(data.message);
     fetchData();
 }
 
 async function testSensor() {
-    const response = await fetch(api_prefix + '/api/v1/sensor/test');
+    const response = await fetch(api_prefix + '/api/v1/sensor/test', { credentials: 'include' });
     const data = await response.json();
     if (data.status === 'success') {
         alert(`Температура: ${data.data.temperature}°C\nВлажность: ${data.data.humidity}%`);
@@ -49,4 +134,4 @@ async function testSensor() {
     }
 }
 
-setInterval(fetchData, 10000);
\ No newline at end of file
+connectStream();
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/tls"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/undeadpelmen/new-client/internal/tlscert"
+)
+
+// loadTLSConfig uses the given certificate, or the self-signed one in dir
+// when certPath is empty.
+func loadTLSConfig(certPath, keyPath, dir, extraHosts string) (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+	if certPath != "" {
+		cert, err = tlscert.Load(certPath, keyPath)
+	} else {
+		hosts := tlscert.DefaultHosts()
+		for _, host := range strings.Split(extraHosts, ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				hosts = append(hosts, host)
+			}
+		}
+		cert, err = tlscert.LoadOrCreate(dir, hosts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("TLS certificate fingerprint (SHA-256): %s", tlscert.Fingerprint(cert))
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// httpsRedirect sends every request to the same host and path on the
+// HTTPS listener.
+func httpsRedirect(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
+
+// localPort turns a listen address into the ":port" suffix of a local URL.
+func localPort(addr string) string {
+	if _, port, err := net.SplitHostPort(addr); err == nil {
+		return ":" + port
+	}
+	return addr
+}